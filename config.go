@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mariomac/linked-release-notes/releasenotes"
+	"gopkg.in/yaml.v3"
+)
+
+// configFileInputs contains the values read from the INPUT_CONFIG_FILE, indexed by the
+// environment variable of the input they replace
+var configFileInputs map[string]string
+
+// loadConfig reads the configuration from the INPUT_* environment variables. If INPUT_CONFIG_FILE
+// is set, the values of the unset environment variables are taken from that YAML file
+func loadConfig() (releasenotes.Options, error) {
+	configFileInputs = nil
+	if path := os.Getenv("INPUT_CONFIG_FILE"); path != "" {
+		inputs, err := readConfigFile(path)
+		if err != nil {
+			return releasenotes.Options{}, err
+		}
+		configFileInputs = inputs
+	}
+	defaults := releasenotes.DefaultOptions()
+	return releasenotes.Options{
+		Token:                    getEnv("INPUT_GITHUB_TOKEN", ""),
+		Repository:               getEnv("INPUT_REPOSITORY", os.Getenv("GITHUB_REPOSITORY")),
+		Tag:                      getEnv("INPUT_TAG", refTag()),
+		PreviousTag:              getEnv("INPUT_PREVIOUS_TAG", ""),
+		TagPrefix:                getEnv("INPUT_TAG_PREFIX", ""),
+		AllowReverse:             getEnvBool("INPUT_ALLOW_REVERSE", false),
+		Since:                    getEnv("INPUT_SINCE", defaults.Since),
+		TagsBack:                 getEnvInt("INPUT_TAGS_BACK", defaults.TagsBack),
+		PerVersion:               getEnvBool("INPUT_PER_VERSION", false),
+		MaxReleasePages:          getEnvInt("INPUT_MAX_RELEASE_PAGES", defaults.MaxReleasePages),
+		GeneratedSubmoduleLink:   getEnv("INPUT_GENERATED_SUBMODULE_LINK", ""),
+		SubmoduleLinks:           getEnv("INPUT_SUBMODULE_LINKS", defaults.SubmoduleLinks),
+		AppID:                    getEnvInt("INPUT_APP_ID", 0),
+		AppInstallationID:        getEnvInt("INPUT_APP_INSTALLATION_ID", 0),
+		AppPrivateKey:            getEnv("INPUT_APP_PRIVATE_KEY", ""),
+		SubmoduleToken:           getEnv("INPUT_SUBMODULE_GITHUB_TOKEN", ""),
+		GitLabToken:              getEnv("INPUT_GITLAB_TOKEN", ""),
+		LogLevel:                 getEnv("INPUT_LOG_LEVEL", defaults.LogLevel),
+		TraceHTTP:                getEnvBool("INPUT_TRACE_HTTP", false),
+		ProxyURL:                 getEnv("INPUT_PROXY_URL", ""),
+		BaseBranch:               getEnv("INPUT_BASE_BRANCH", ""),
+		HeadBranch:               getEnv("INPUT_HEAD_BRANCH", ""),
+		ExcludeCommitAuthors:     getEnvList("INPUT_EXCLUDE_COMMIT_AUTHORS"),
+		IncludePattern:           getEnv("INPUT_INCLUDE_PATTERN", ""),
+		ExcludePattern:           getEnv("INPUT_EXCLUDE_PATTERN", ""),
+		ExcludeReleased:          getEnvBool("INPUT_EXCLUDE_RELEASED", false),
+		UseMergeBase:             getEnvBool("INPUT_USE_MERGE_BASE", false),
+		SectionTitlePrefix:       getEnv("INPUT_SECTION_TITLE_PREFIX", ""),
+		SubmoduleHeadingTemplate: getEnv("INPUT_SUBMODULE_HEADING_TEMPLATE", ""),
+		LinkSections:             getEnvBool("INPUT_LINK_SECTIONS", false),
+		PathFilter:               getEnvList("INPUT_PATH_FILTER"),
+		ExcludeSubmoduleBumps:    getEnvBool("INPUT_EXCLUDE_SUBMODULE_BUMPS", false),
+		SubmoduleFilter:          getEnv("INPUT_SUBMODULE_FILTER", ""),
+		ExcludeSubmodules:        getEnvList("INPUT_EXCLUDE_SUBMODULES"),
+		SubmoduleLockfile:        getEnv("INPUT_SUBMODULE_LOCKFILE", ""),
+		LockfileSHAPattern:       getEnv("INPUT_LOCKFILE_SHA_PATTERN", ""),
+		SubmodulePathFilter:      getEnv("INPUT_SUBMODULE_PATH_FILTER", ""),
+		SubmodulePointerSummary:  getEnvBool("INPUT_SUBMODULE_POINTER_SUMMARY", false),
+		SubmoduleBumpSummary:     getEnvBool("INPUT_SUBMODULE_BUMP_SUMMARY", false),
+		ShowSummary:              getEnvBool("INPUT_SHOW_SUMMARY", false),
+		SkipSubmodules:           !getEnvBool("INPUT_INCLUDE_SUBMODULES", true),
+		CollapseSubmodules:       getEnvBool("INPUT_COLLAPSE_SUBMODULES", false),
+		ShowDates:                getEnvBool("INPUT_SHOW_DATES", false),
+		DateFormat:               getEnv("INPUT_DATE_FORMAT", ""),
+		ShowDiffstat:             getEnvBool("INPUT_SHOW_DIFFSTAT", false),
+		ResolveReferences:        getEnvBool("INPUT_RESOLVE_REFERENCES", false),
+		CollapseReverts:          getEnvBool("INPUT_COLLAPSE_REVERTS", false),
+		Format:                   getEnv("INPUT_FORMAT", defaults.Format),
+		SectionOrder:             getEnvList("INPUT_SECTION_ORDER"),
+		Header:                   getEnv("INPUT_HEADER", ""),
+		Footer:                   getEnv("INPUT_FOOTER", ""),
+		OutputFile:               getEnv("INPUT_OUTPUT_FILE", ""),
+		WriteStepSummary:         getEnvBool("INPUT_WRITE_STEP_SUMMARY", false),
+		ChangelogMode:            getEnvBool("INPUT_CHANGELOG_MODE", false),
+		Mode:                     getEnv("INPUT_MODE", defaults.Mode),
+		Publish:                  getEnvBool("INPUT_PUBLISH", false),
+		Draft:                    getEnvBool("INPUT_DRAFT", false),
+		Prerelease:               getEnvBool("INPUT_PRERELEASE", false),
+		PRNumber:                 getEnvInt("INPUT_PR_NUMBER", 0),
+		FallbackLastNCommits:     getEnvInt("INPUT_FALLBACK_LAST_N_COMMITS", 0),
+		Timeout:                  getEnvDuration("INPUT_TIMEOUT", defaults.Timeout),
+		CacheDir:                 getEnv("INPUT_CACHE_DIR", ""),
+		CacheTTL:                 getEnvDuration("INPUT_CACHE_TTL", defaults.CacheTTL),
+		GroupByLabel:             getEnvBool("INPUT_GROUP_BY_LABEL", false),
+		Layout:                   getEnv("INPUT_LAYOUT", defaults.Layout),
+		GroupByPR:                getEnvBool("INPUT_GROUP_BY_PR", false),
+		LabelPriority:            getEnvList("INPUT_LABEL_PRIORITY"),
+		GroupRules:               getEnv("INPUT_GROUP_RULES", ""),
+		UseReleaseConfig:         getEnvBool("INPUT_USE_RELEASE_CONFIG", false),
+		SubjectMode:              getEnv("INPUT_SUBJECT_MODE", defaults.SubjectMode),
+		PRSuffix:                 getEnv("INPUT_PR_SUFFIX", defaults.PRSuffix),
+		EscapeMarkdown:           getEnvBool("INPUT_ESCAPE_MARKDOWN", false),
+		IncludeBody:              getEnvBool("INPUT_INCLUDE_BODY", false),
+		BreakingChanges:          getEnvBool("INPUT_BREAKING_CHANGES", false),
+		ShowVerification:         getEnvBool("INPUT_SHOW_VERIFICATION", false),
+		Concurrency:              getEnvInt("INPUT_CONCURRENCY", defaults.Concurrency),
+		SubmoduleOrder:           getEnv("INPUT_SUBMODULE_ORDER", defaults.SubmoduleOrder),
+		RecursiveDepth:           getEnvInt("INPUT_RECURSIVE_DEPTH", 0),
+		UseGitHubNotes:           getEnvBool("INPUT_USE_GITHUB_NOTES", false),
+		MaxEntries:               getEnvInt("INPUT_MAX_ENTRIES", 0),
+		MaxWords:                 getEnvInt("INPUT_MAX_WORDS", 0),
+	}, nil
+}
+
+// readConfigFile reads a YAML file whose keys are the action input names (e.g. github_token or
+// max_entries), and returns their values indexed by the environment variable of each input.
+// Lists are converted to comma-separated values
+func readConfigFile(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+	values := map[string]any{}
+	if err := yaml.Unmarshal(content, &values); err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	inputs := make(map[string]string, len(values))
+	for key, value := range values {
+		envName := "INPUT_" + strings.ToUpper(key)
+		switch v := value.(type) {
+		case nil:
+		case []any:
+			elems := make([]string, 0, len(v))
+			for _, elem := range v {
+				elems = append(elems, fmt.Sprint(elem))
+			}
+			inputs[envName] = strings.Join(elems, ",")
+		case map[string]any:
+			return nil, fmt.Errorf("config file %s: unsupported value for %s", path, key)
+		default:
+			inputs[envName] = fmt.Sprint(v)
+		}
+	}
+	return inputs, nil
+}
+
+// lookupInput returns the value of the environment variable, or the value of the config file
+// if the variable is unset or empty
+func lookupInput(key string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return configFileInputs[key]
+}
+
+// refTag returns the tag that triggered the workflow, or an empty string if the workflow was
+// triggered by any other ref, like a branch
+func refTag() string {
+	if tag, ok := strings.CutPrefix(os.Getenv("GITHUB_REF"), "refs/tags/"); ok {
+		return tag
+	}
+	if os.Getenv("GITHUB_REF_TYPE") == "tag" {
+		return os.Getenv("GITHUB_REF_NAME")
+	}
+	return ""
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := lookupInput(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value := lookupInput(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("invalid integer value. Using default", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value := lookupInput(key)
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Warn("invalid boolean value. Using default", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return b
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := lookupInput(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("invalid duration value. Using default", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+// getEnvList returns the non-empty elements of a comma-separated list
+func getEnvList(key string) []string {
+	var list []string
+	for _, elem := range strings.Split(lookupInput(key), ",") {
+		if elem = strings.TrimSpace(elem); elem != "" {
+			list = append(list, elem)
+		}
+	}
+	return list
+}
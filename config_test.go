@@ -0,0 +1,197 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mariomac/linked-release-notes/releasenotes"
+	"gopkg.in/yaml.v3"
+)
+
+func TestLoadConfig(t *testing.T) {
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Test that config loads without panicking
+	if config.Token != "" && config.Repository == "" {
+		t.Error("Expected repository to be set when token is set")
+	}
+}
+
+func TestLoadConfig_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	err := os.WriteFile(path, []byte(`
+repository: owner/repo
+tag: v1.0.0
+max_entries: 20
+group_by_label: true
+label_priority: [breaking, enhancement]
+cache_ttl: 1h
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("INPUT_CONFIG_FILE", path)
+	t.Cleanup(func() { configFileInputs = nil })
+	// environment variables take precedence over the file
+	t.Setenv("INPUT_TAG", "v2.0.0")
+	t.Setenv("INPUT_MAX_ENTRIES", "")
+	t.Setenv("INPUT_FORMAT", releasenotes.FormatNDJSON)
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.Repository != "owner/repo" || config.Tag != "v2.0.0" || config.MaxEntries != 20 ||
+		!config.GroupByLabel || config.CacheTTL != time.Hour || config.Format != releasenotes.FormatNDJSON ||
+		!reflect.DeepEqual(config.LabelPriority, []string{"breaking", "enhancement"}) {
+		t.Errorf("unexpected config: %+v", config)
+	}
+}
+
+func TestLoadConfig_DefaultRepository(t *testing.T) {
+	t.Setenv("GITHUB_REPOSITORY", "current/repo")
+	t.Setenv("INPUT_REPOSITORY", "")
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.Repository != "current/repo" {
+		t.Errorf("repository = %q, want the GITHUB_REPOSITORY", config.Repository)
+	}
+
+	t.Setenv("INPUT_REPOSITORY", "other/repo")
+	if config, _ = loadConfig(); config.Repository != "other/repo" {
+		t.Errorf("repository = %q, want the input", config.Repository)
+	}
+}
+
+func TestLoadConfig_RefTag(t *testing.T) {
+	t.Setenv("INPUT_TAG", "")
+	for _, tt := range []struct{ ref, refType, refName, want string }{
+		{ref: "refs/tags/v1.2.3", refType: "tag", refName: "v1.2.3", want: "v1.2.3"},
+		{ref: "refs/tags/module-a/v1.2.3", want: "module-a/v1.2.3"},
+		{refType: "tag", refName: "v2.0.0", want: "v2.0.0"},
+		{ref: "refs/heads/main", refType: "branch", refName: "main", want: ""},
+	} {
+		t.Setenv("GITHUB_REF", tt.ref)
+		t.Setenv("GITHUB_REF_TYPE", tt.refType)
+		t.Setenv("GITHUB_REF_NAME", tt.refName)
+		config, err := loadConfig()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if config.Tag != tt.want {
+			t.Errorf("tag for ref %q = %q, want %q", tt.ref, config.Tag, tt.want)
+		}
+	}
+}
+
+func TestLoadConfig_InvalidFile(t *testing.T) {
+	t.Setenv("INPUT_CONFIG_FILE", filepath.Join(t.TempDir(), "missing.yml"))
+	if _, err := loadConfig(); err == nil {
+		t.Error("expected error for missing config file")
+	}
+}
+
+func TestGetEnv(t *testing.T) {
+	tests := []struct {
+		name         string
+		key          string
+		defaultValue string
+		want         string
+	}{
+		{
+			name:         "returns default when env not set",
+			key:          "NONEXISTENT_KEY",
+			defaultValue: "default",
+			want:         "default",
+		},
+		{
+			name:         "returns empty default",
+			key:          "NONEXISTENT_KEY",
+			defaultValue: "",
+			want:         "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getEnv(tt.key, tt.defaultValue)
+			if got != tt.want {
+				t.Errorf("getEnv() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_IncludeSubmodules(t *testing.T) {
+	t.Setenv("INPUT_INCLUDE_SUBMODULES", "")
+	if config, err := loadConfig(); err != nil || config.SkipSubmodules {
+		t.Errorf("the submodules must be included by default: %+v, %v", config, err)
+	}
+	t.Setenv("INPUT_INCLUDE_SUBMODULES", "false")
+	if config, err := loadConfig(); err != nil || !config.SkipSubmodules {
+		t.Errorf("the submodules must be skipped: %+v, %v", config, err)
+	}
+}
+
+// TestLoadConfig_FileWithActionDefaults checks that the config file can set the inputs when the
+// action runner exports the action.yml defaults as INPUT_* environment variables
+func TestLoadConfig_FileWithActionDefaults(t *testing.T) {
+	content, err := os.ReadFile("action.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var action struct {
+		Inputs map[string]struct {
+			Default string `yaml:"default"`
+		} `yaml:"inputs"`
+	}
+	if err := yaml.Unmarshal(content, &action); err != nil {
+		t.Fatal(err)
+	}
+	expressions := map[string]string{"${{ github.token }}": "workflow-token", "${{ github.ref_name }}": "main"}
+	for name, input := range action.Inputs {
+		value := input.Default
+		if expr, ok := expressions[value]; ok {
+			value = expr
+		} else if strings.HasPrefix(value, "${{") {
+			t.Fatalf("unexpected expression in the default of %s: %s", name, value)
+		}
+		t.Setenv("INPUT_"+strings.ToUpper(name), value)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yml")
+	err = os.WriteFile(path, []byte(`
+repository: owner/repo
+group_by_label: true
+since: last-release
+format: ndjson
+section_title_prefix: Changes in
+concurrency: 8
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("INPUT_CONFIG_FILE", path)
+	t.Cleanup(func() { configFileInputs = nil })
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.Repository != "owner/repo" || !config.GroupByLabel || config.Since != releasenotes.SinceLastRelease ||
+		config.Format != releasenotes.FormatNDJSON || config.SectionTitlePrefix != "Changes in" || config.Concurrency != 8 {
+		t.Errorf("the config file must override the action defaults: %+v", config)
+	}
+	if config.Token != "workflow-token" || config.Tag != "main" {
+		t.Errorf("unexpected token or tag: %+v", config)
+	}
+}
@@ -6,6 +6,7 @@ require (
 	github.com/google/go-github/v57 v57.0.0
 	golang.org/x/mod v0.28.0
 	golang.org/x/oauth2 v0.31.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require github.com/google/go-querystring v1.1.0 // indirect
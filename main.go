@@ -2,356 +2,151 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
-	"regexp"
 	"strings"
 
 	"github.com/google/go-github/v57/github"
-	"golang.org/x/mod/semver"
-	"golang.org/x/oauth2"
+	"github.com/mariomac/linked-release-notes/releasenotes"
 )
 
-type Config struct {
-	Token                  string
-	Repository             string
-	Tag                    string
-	PreviousTag            string
-	GeneratedSubmoduleLink string
-}
-
 func main() {
-	config := loadConfig()
-
-	if err := run(config); err != nil {
-		log.Printf("Error: %v\n", err)
+	config, err := loadConfig()
+	if err != nil {
+		slog.Error("can't load configuration", "error", err)
 		os.Exit(1)
 	}
-}
-
-func loadConfig() Config {
-	return Config{
-		Token:                  getEnv("INPUT_GITHUB_TOKEN", ""),
-		Repository:             getEnv("INPUT_REPOSITORY", ""),
-		Tag:                    getEnv("INPUT_TAG", ""),
-		PreviousTag:            getEnv("INPUT_PREVIOUS_TAG", ""),
-		GeneratedSubmoduleLink: getEnv("INPUT_GENERATED_SUBMODULE_LINK", ""),
+	setupLogger(config.LogLevel)
+	if err := config.Validate(); err != nil {
+		slog.Error("invalid configuration", "error", err)
+		os.Exit(1)
 	}
-}
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+	if err := run(config); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			slog.Error("release notes generation timed out. Consider increasing the timeout input",
+				"timeout", config.Timeout, "error", err)
+			os.Exit(1)
+		}
+		slog.Error("can't generate release notes", "error", err)
+		os.Exit(1)
 	}
-	return defaultValue
 }
 
-type ReleaseNotesWriter struct {
-	config      Config
-	client      *github.Client
-	previousTag string
+// setupLogger sends the diagnostic messages to the standard error, so the standard output
+// only contains the generated release notes
+func setupLogger(level string) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		slog.Warn("invalid log level. Using info", "level", level)
+		lvl = slog.LevelInfo
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: lvl})))
 }
 
-func run(config Config) error {
+// run generates the release notes and writes them to the standard output and the action outputs,
+// as well as to the output file or the published release, if requested
+func run(config releasenotes.Options) error {
 	ctx := context.Background()
-
-	// Setup GitHub client
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: config.Token},
-	)
-	tc := oauth2.NewClient(ctx, ts)
-	client := github.NewClient(tc)
-
-	// Parse repository name
-	parts := strings.Split(config.Repository, "/")
-	if len(parts) != 2 {
-		return fmt.Errorf("invalid repository format: %s (expected owner/repo)", config.Repository)
-	}
-
-	owner, repo := parts[0], parts[1]
-	rnw := ReleaseNotesWriter{config: config, client: client}
-	if err := rnw.fetchPreviousTag(ctx, owner, repo); err != nil {
-		return fmt.Errorf("fetching previous tag: %w", err)
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
 	}
-	log.Println("Previous tag:", rnw.previousTag)
 
-	// Get release changes for main repository
-	commit, prevCommit, changes, err := rnw.changesForMain(ctx, owner, repo)
+	// Setup GitHub client
+	httpClient, err := releasenotes.NewHTTPClient(ctx, config)
 	if err != nil {
 		return err
 	}
-	log.Println("Commit:", commit)
-	log.Println("Previous commit:", prevCommit)
+	client := github.NewClient(httpClient)
 
-	// get release changes for submodule repository
-	submoduleRepository, smChanges, err := rnw.getChangesForSubmodule(ctx, owner, repo, commit, prevCommit)
+	result, err := releasenotes.GenerateNotes(ctx, client, config)
 	if err != nil {
 		return err
 	}
-
-	// In submodule, replaces #PR_NUMBER by repo/name#PR_NUMBER for proper linking from GitHub
-	rnw.replaceSubmoduleLinks(smChanges)
-
-	// Combine release notes
-	finalNotes := fmt.Sprintf("## Changes from %s/%s:\n%s\n", owner, repo, strings.Join(changes, "\n"))
-	finalNotes += fmt.Sprintf("\n## Changes from %s:\n%s\n", submoduleRepository, strings.Join(smChanges, "\n"))
-
-	// Set outputs
-	setOutput("release_notes", finalNotes)
-
-	fmt.Println("\n\nRelease notes generated successfully:")
-	fmt.Println(finalNotes)
-	return nil
-}
-
-func (rnw *ReleaseNotesWriter) getChangesForSubmodule(
-	ctx context.Context, owner string, repo string, commit string, prevCommit string,
-) (
-	submoduleRepoName string, submoduleChanges []string, err error,
-) {
-	var submodulePath string
-	submodulePath, submoduleRepoName, err = rnw.getSubmodulePathRepo(ctx, owner, repo, commit)
-	if err != nil {
-		err = fmt.Errorf("failed to get submodule path and repository: %w", err)
-		return
-	}
-	log.Printf("Submodule path: %s\n", submodulePath)
-	log.Printf("Submodule repository: %s\n", submoduleRepoName)
-
-	var smChanges []string
-	if submodulePath == "" || submoduleRepoName == "" {
-		log.Printf("No submodule repository found")
-		return
-	}
-	if rnw.config.GeneratedSubmoduleLink == "" {
-		rnw.config.GeneratedSubmoduleLink = submoduleRepoName
-	}
-
-	// get the changes for the submodule commits
-	oldSMCommit, newSMCommit, err := rnw.getSubmoduleCommits(ctx, owner, repo, prevCommit, commit, submodulePath)
-	if err != nil {
-		return "", nil, fmt.Errorf("failed to get submodule commits: %w", err)
-	}
-	log.Printf("Old submodule commit: %s\n", oldSMCommit[:8])
-	log.Printf("New submodule commit: %s\n", newSMCommit[:8])
-	parts := strings.Split(submoduleRepoName, "/")
-	if len(parts) != 2 {
-		return "", nil, fmt.Errorf("invalid submodule repository format: %s (expected owner/repo)", submoduleRepoName)
-	}
-	smOwner, smRepo := parts[0], parts[1]
-	smChanges, err = rnw.getChanges(ctx, smOwner, smRepo, newSMCommit, oldSMCommit)
-	if err != nil {
-		return "", nil, fmt.Errorf("failed to get submodule changes: %w", err)
-	}
-
-	return submoduleRepoName, smChanges, nil
-}
-
-// gets each release notes entry for the main branch
-func (rnw *ReleaseNotesWriter) changesForMain(
-	ctx context.Context, owner string, repo string,
-) (
-	commit, prevCommit string, changes []string, err error,
-) {
-	commit, err = rnw.commitForTag(ctx, owner, repo, rnw.config.Tag)
-	if err != nil {
-		// TODO: if tag does not exist, default to branch's latest commit
-		err = fmt.Errorf("failed to get commit for tag: %w", err)
-		return
-	}
-	prevCommit, err = rnw.commitForTag(ctx, owner, repo, rnw.previousTag)
-	if err != nil {
-		err = fmt.Errorf("failed to get commit for previous tag: %w", err)
-		return
-	}
-	changes, err = rnw.getChanges(ctx, owner, repo, commit, prevCommit)
-	if err != nil {
-		fmt.Errorf("failed to get changes: %w", err)
-		return
+	finalNotes := result.Notes
+	if config.Format == releasenotes.FormatMarkdown {
+		fmt.Println(finalNotes)
+	} else if err := releasenotes.WriteNDJSON(os.Stdout, config, result); err != nil {
+		return fmt.Errorf("writing NDJSON notes: %w", err)
 	}
-	return
-}
 
-// If PreviousTag is not set, find the previous tag by iterating through all the releases and getting
-// the semantically previous, non-prerelease tag
-func (rnw *ReleaseNotesWriter) fetchPreviousTag(ctx context.Context, owner, repo string) error {
-	if rnw.config.PreviousTag != "" {
-		rnw.previousTag = rnw.config.PreviousTag
-		return nil
-	}
-	var tags []string
-	for page := 1; ; page++ {
-		releases, resp, err := rnw.client.Repositories.ListReleases(ctx, owner, repo, &github.ListOptions{Page: page, PerPage: 100})
-		if err != nil {
+	if config.Mode == releasenotes.ModeVerify {
+		if err := releasenotes.VerifySnapshot(config.OutputFile, finalNotes); err != nil {
 			return err
 		}
-		for _, release := range releases {
-			if release.TagName != nil && *release.TagName != "" {
-				tn := *release.TagName
-				// discard prereleases
-				fmt.Println(tn)
-				if !strings.Contains(tn, "-") {
-					tags = append(tags, tn)
-				}
-			}
-		}
-		if page >= resp.LastPage {
-			break
-		}
-	}
-	semver.Sort(tags)
-	log.Println("tags: ", tags)
-	if len(tags) == 0 {
+		slog.Info("release notes are up to date", "file", config.OutputFile)
 		return nil
 	}
-	if rnw.config.Tag == "" {
-		rnw.previousTag = tags[len(tags)-1]
-		return nil
-	}
-	i := len(tags) - 1
-	for semver.Compare(rnw.config.Tag, tags[i]) <= 0 {
-		i--
-		if i < 0 {
-			rnw.previousTag = tags[len(tags)-1]
-			return nil
-		}
-	}
-	rnw.previousTag = tags[i]
-	return nil
-}
 
-func (rnw *ReleaseNotesWriter) commitForTag(ctx context.Context, owner, repo, tag string) (string, error) {
-	ref, _, err := rnw.client.Git.GetRef(ctx, owner, repo, "tags/"+tag)
+	// Set outputs
+	setOutput("release_notes", finalNotes)
+	entries, err := json.Marshal(result.ChangelogEntries())
 	if err != nil {
-		return "", fmt.Errorf("failed to get tag reference: %rnw", err)
+		return fmt.Errorf("encoding changelog entries: %w", err)
 	}
-	return ref.Object.GetSHA(), nil
-}
-
-func (rnw *ReleaseNotesWriter) getChanges(ctx context.Context, owner, repo, commit, prevCommit string) ([]string, error) {
-	comparison, _, err := rnw.client.Repositories.CompareCommits(ctx, owner, repo, prevCommit, commit, nil)
+	setOutput("changelog_entries", string(entries))
+	setOutput("release_date", result.ReleaseDate)
+	atomEntry, err := releasenotes.AtomEntry(config, result)
 	if err != nil {
-		return nil, fmt.Errorf("failed to compare commits: %rnw", err)
+		return fmt.Errorf("encoding atom entry: %w", err)
 	}
-
-	var changes []string
-	for _, commit := range comparison.Commits {
-		if commit.Commit != nil && commit.Commit.Message != nil {
-			message := strings.Split(*commit.Commit.Message, "\n")[0]
-			changes = append(changes, "* "+message)
+	setOutput("atom_entry", atomEntry)
+	if config.WriteStepSummary {
+		if err := writeStepSummary(finalNotes, config.Format); err != nil {
+			return fmt.Errorf("writing step summary: %w", err)
 		}
 	}
-	return changes, nil
-}
-
-func (rnw *ReleaseNotesWriter) generateReleaseNotes(ctx context.Context, owner, repo string) (string, error) {
-	// Generate release notes using GitHub API
-	notes, _, err := rnw.client.Repositories.GenerateReleaseNotes(ctx, owner, repo, &github.GenerateNotesOptions{
-		TagName:         rnw.config.Tag,
-		PreviousTagName: &rnw.config.PreviousTag,
-	})
-	if err != nil {
-		return "", err
-	}
-
-	return notes.Body, nil
-}
-
-func (rnw *ReleaseNotesWriter) getSubmoduleCommits(ctx context.Context, owner, repo, oldCommit, newCommit, submodulePath string) (old, new string, err error) {
-	// Get submodule commit at old tag
-	oldTree, _, err := rnw.client.Git.GetTree(ctx, owner, repo, oldCommit, true)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to get old tree: %rnw", err)
-	}
-
-	oldSubmoduleCommit := ""
-	for _, entry := range oldTree.Entries {
-		if entry.GetPath() == submodulePath && entry.GetType() == "commit" {
-			oldSubmoduleCommit = entry.GetSHA()
-			break
+	if config.ChangelogMode {
+		if err := releasenotes.UpdateChangelog(config.OutputFile, config.Tag, finalNotes); err != nil {
+			return fmt.Errorf("updating changelog: %w", err)
+		}
+	} else if config.OutputFile != "" {
+		if err := os.WriteFile(config.OutputFile, []byte(finalNotes), 0644); err != nil {
+			return fmt.Errorf("writing output file: %w", err)
 		}
 	}
-
-	// Get submodule commit at new tag
-	newTree, _, err := rnw.client.Git.GetTree(ctx, owner, repo, newCommit, true)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to get new tree: %rnw", err)
-	}
-
-	newSubmoduleCommit := ""
-	for _, entry := range newTree.Entries {
-		if entry.GetPath() == submodulePath && entry.GetType() == "commit" {
-			newSubmoduleCommit = entry.GetSHA()
-			break
+	if config.Publish {
+		releaseURL, err := releasenotes.PublishRelease(ctx, client, config, result)
+		if err != nil {
+			return fmt.Errorf("publishing release: %w", err)
 		}
+		setOutput("release_url", releaseURL)
 	}
-
-	if oldSubmoduleCommit == "" || newSubmoduleCommit == "" {
-		return "", "", fmt.Errorf("submodule not found in one or both tags")
+	if config.PRNumber != 0 {
+		commentURL, err := releasenotes.CommentPullRequest(ctx, client, config, result)
+		if err != nil {
+			return fmt.Errorf("commenting pull request: %w", err)
+		}
+		setOutput("comment_url", commentURL)
 	}
 
-	return oldSubmoduleCommit, newSubmoduleCommit, nil
+	slog.Info("release notes generated successfully")
+	return nil
 }
 
-func (rnw *ReleaseNotesWriter) getSubmodulePathRepo(ctx context.Context, owner, repo, commit string) (string, string, error) {
-	// Get release notes for submodule repository
-	// Read .gitmodules file
-	// Get the .gitmodules file content from the repository at a specific commit
-	gitmodulesContent, _, _, err := rnw.client.Repositories.GetContents(ctx, owner, repo, ".gitmodules", &github.RepositoryContentGetOptions{
-		Ref: commit, // or tag, branch name
-	})
-	if err != nil {
-		return "", "", fmt.Errorf("failed to read .gitmodules from repository: %rnw", err)
-	}
-
-	// Decode the content (GitHub API returns base64-encoded content)
-	content, err := gitmodulesContent.GetContent()
-	if err != nil {
-		return "", "", fmt.Errorf("failed to decode .gitmodules content: %rnw", err)
+// writeStepSummary appends the notes to the job summary of the GitHub Actions run, if available.
+// NDJSON notes are shown as a code block
+func writeStepSummary(notes, format string) error {
+	summaryFile := os.Getenv("GITHUB_STEP_SUMMARY")
+	if summaryFile == "" {
+		slog.Warn("GITHUB_STEP_SUMMARY is not set. Skipping the step summary")
+		return nil
 	}
-
-	var submodulePath, submoduleRepo string
-
-	// Parse the .gitmodules file
-	lines := strings.Split(content, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-
-		// Extract path
-		if strings.HasPrefix(line, "path = ") {
-			submodulePath = strings.TrimPrefix(line, "path = ")
-		}
-
-		// Extract URL and convert to owner/repo format
-		if strings.HasPrefix(line, "url = ") {
-			url := strings.TrimSpace(strings.TrimPrefix(line, "url = "))
-			// Remove .git suffix if present
-			url = strings.TrimSuffix(url, ".git")
-			if strings.HasPrefix(url, "http") {
-				// Extract owner/repo from URL (e.g., https://github.com/grafana/opentelemetry-ebpf-instrumentation.git)
-				parts := strings.Split(url, "/")
-				if len(parts) >= 2 {
-					submoduleRepo = parts[len(parts)-2] + "/" + parts[len(parts)-1]
-				}
-			} else if strings.HasPrefix(url, "git@") {
-				parts := strings.Split(url, ":")
-				if len(parts) >= 2 {
-					submoduleRepo = parts[1]
-				}
-			}
-		}
+	if format == releasenotes.FormatNDJSON {
+		notes = "```json\n" + strings.TrimSuffix(notes, "\n") + "\n```"
 	}
-	return submodulePath, submoduleRepo, nil
-}
-
-func (rnw *ReleaseNotesWriter) replaceSubmoduleLinks(entries []string) {
-	var linkNum = regexp.MustCompile(`#\d+($|\W)`)
-	for i := range entries {
-		entries[i] = linkNum.ReplaceAllStringFunc(entries[i], func(s string) string {
-			return rnw.config.GeneratedSubmoduleLink + s
-		})
+	f, err := os.OpenFile(summaryFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
 	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "%s\n", strings.TrimSuffix(notes, "\n"))
+	return err
 }
 
 func setOutput(name, value string) {
@@ -361,9 +156,14 @@ func setOutput(name, value string) {
 		f, err := os.OpenFile(outputFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 		if err == nil {
 			defer f.Close()
-			// Use multiline format for release notes
-			if name == "release_notes" {
-				fmt.Fprintf(f, "%s<<EOF\n%s\nEOF\n", name, value)
+			// Use multiline format for release notes and any value that spans several lines, with a
+			// delimiter that does not appear in the value
+			if name == "release_notes" || strings.ContainsAny(value, "\r\n") {
+				delimiter := "EOF"
+				for strings.Contains(value, delimiter) {
+					delimiter += "_"
+				}
+				fmt.Fprintf(f, "%s<<%s\n%s\n%s\n", name, delimiter, value, delimiter)
 			} else {
 				fmt.Fprintf(f, "%s=%s\n", name, value)
 			}
@@ -1,45 +1,59 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/mariomac/linked-release-notes/releasenotes"
 )
 
-func TestLoadConfig(t *testing.T) {
-	config := loadConfig()
+func TestSetOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output")
+	t.Setenv("GITHUB_OUTPUT", path)
+
+	setOutput("release_url", "https://github.com/owner/repo/releases/tag/v1.0.0")
+	setOutput("release_notes", "## Changes\nEOF\n* Fix")
+	setOutput("changelog_entries", "[\n]")
 
-	// Test that config loads without panicking
-	if config.Token != "" && config.Repository == "" {
-		t.Error("Expected repository to be set when token is set")
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "release_url=https://github.com/owner/repo/releases/tag/v1.0.0\n" +
+		// the delimiter must not appear in the value
+		"release_notes<<EOF_\n## Changes\nEOF\n* Fix\nEOF_\n" +
+		"changelog_entries<<EOF\n[\n]\nEOF\n"
+	if string(content) != want {
+		t.Errorf("outputs = %q, want %q", content, want)
 	}
 }
 
-func TestGetEnv(t *testing.T) {
-	tests := []struct {
-		name         string
-		key          string
-		defaultValue string
-		want         string
-	}{
-		{
-			name:         "returns default when env not set",
-			key:          "NONEXISTENT_KEY",
-			defaultValue: "default",
-			want:         "default",
-		},
-		{
-			name:         "returns empty default",
-			key:          "NONEXISTENT_KEY",
-			defaultValue: "",
-			want:         "",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := getEnv(tt.key, tt.defaultValue)
-			if got != tt.want {
-				t.Errorf("getEnv() = %v, want %v", got, tt.want)
-			}
-		})
+func TestWriteStepSummary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "summary")
+	if err := os.WriteFile(path, []byte("previous step\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GITHUB_STEP_SUMMARY", path)
+
+	if err := writeStepSummary("## Changes\n* Fix\n", releasenotes.FormatMarkdown); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeStepSummary(`{"type":"metadata"}`+"\n", releasenotes.FormatNDJSON); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "previous step\n## Changes\n* Fix\n```json\n{\"type\":\"metadata\"}\n```\n"
+	if string(content) != want {
+		t.Errorf("step summary = %q, want %q", content, want)
+	}
+
+	// nothing is written out of GitHub Actions
+	t.Setenv("GITHUB_STEP_SUMMARY", "")
+	if err := writeStepSummary("## Changes", releasenotes.FormatMarkdown); err != nil {
+		t.Errorf("unexpected error: %v", err)
 	}
 }
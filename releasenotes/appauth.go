@@ -0,0 +1,125 @@
+package releasenotes
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+	"golang.org/x/oauth2"
+)
+
+// appJWTLifetime is the validity of the JWTs that authenticate as the GitHub App. GitHub
+// accepts up to 10 minutes
+const appJWTLifetime = 9 * time.Minute
+
+// newHTTPClient returns the HTTP client that authenticates the GitHub API requests, either as
+// a GitHub App installation, when the App credentials are provided, or with the GitHub token
+func newHTTPClient(ctx context.Context, config Options) (*http.Client, error) {
+	ctx = withTransport(ctx, config)
+	if config.AppID == 0 {
+		return oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: config.Token})), nil
+	}
+	ts, err := newAppTokenSource(github.NewClient(&http.Client{Transport: newTransport(config)}), config.AppID, config.AppInstallationID, config.AppPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	// the installation token is reused until it is about to expire
+	return oauth2.NewClient(ctx, oauth2.ReuseTokenSource(nil, ts)), nil
+}
+
+// appTokenSource provides installation access tokens for a GitHub App
+type appTokenSource struct {
+	// client is used to request the installation tokens. It is authenticated per request with
+	// a JWT signed by the App private key
+	client         *github.Client
+	appID          int64
+	installationID int64
+	key            *rsa.PrivateKey
+	now            func() time.Time
+}
+
+func newAppTokenSource(client *github.Client, appID, installationID int, privateKey string) (*appTokenSource, error) {
+	key, err := parseAppPrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return &appTokenSource{
+		client:         client,
+		appID:          int64(appID),
+		installationID: int64(installationID),
+		key:            key,
+		now:            time.Now,
+	}, nil
+}
+
+// Token creates a new installation access token
+func (ts *appTokenSource) Token() (*oauth2.Token, error) {
+	jwt, err := ts.jwt()
+	if err != nil {
+		return nil, err
+	}
+	// the request is authenticated by hand, as github.Client.WithAuthToken would wrap the shared
+	// transport again on each token renewal, sending the first (expired) JWT
+	req, err := ts.client.NewRequest(http.MethodPost, fmt.Sprintf("app/installations/%d/access_tokens", ts.installationID), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	token := &github.InstallationToken{}
+	if _, err := ts.client.Do(context.Background(), req, token); err != nil {
+		return nil, fmt.Errorf("failed to create GitHub App installation token: %w", err)
+	}
+	return &oauth2.Token{AccessToken: token.GetToken(), Expiry: token.GetExpiresAt().Time}, nil
+}
+
+// jwt returns a RS256 JSON Web Token that authenticates as the GitHub App
+func (ts *appTokenSource) jwt() (string, error) {
+	now := ts.now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]any{
+		// backdated to tolerate clock drift with GitHub
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(appJWTLifetime).Unix(),
+		"iss": strconv.FormatInt(ts.appID, 10),
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, ts.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign GitHub App JWT: %w", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parseAppPrivateKey parses the PEM-encoded RSA private key of the GitHub App, either in the
+// PKCS #1 format that GitHub generates or in PKCS #8
+func parseAppPrivateKey(privateKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(privateKey))
+	if block == nil {
+		return nil, errors.New("invalid GitHub App private key: no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GitHub App private key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("invalid GitHub App private key: not an RSA key")
+	}
+	return rsaKey, nil
+}
@@ -0,0 +1,106 @@
+package releasenotes
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+)
+
+func TestAppTokenSource(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	mux := http.NewServeMux()
+	var jwts []string
+	mux.HandleFunc("POST /app/installations/42/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+		jwt, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			t.Fatalf("missing bearer JWT: %q", r.Header.Get("Authorization"))
+		}
+		parts := strings.Split(jwt, ".")
+		if len(parts) != 3 {
+			t.Fatalf("malformed JWT: %q", jwt)
+		}
+		jwts = append(jwts, jwt)
+		signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+			t.Errorf("invalid JWT signature: %v", err)
+		}
+		rawClaims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		var claims struct {
+			Iss string `json:"iss"`
+			Iat int64  `json:"iat"`
+			Exp int64  `json:"exp"`
+		}
+		if err := json.Unmarshal(rawClaims, &claims); err != nil {
+			t.Fatal(err)
+		}
+		if claims.Iss != "7" || claims.Iat != now.Add(-time.Minute).Unix() || claims.Exp != now.Add(appJWTLifetime).Unix() {
+			t.Errorf("unexpected JWT claims: %+v", claims)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"token": "ghs_installation", "expires_at": "2024-06-01T13:00:00Z"}`))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+
+	ts, err := newAppTokenSource(client, 7, 42, pemKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.now = func() time.Time { return now }
+	token, err := ts.Token()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token.AccessToken != "ghs_installation" || !token.Expiry.Equal(now.Add(time.Hour)) {
+		t.Errorf("unexpected token: %+v", token)
+	}
+
+	// renewals are authenticated with a new JWT
+	now = now.Add(time.Hour)
+	if _, err := ts.Token(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(jwts) != 2 || jwts[0] == jwts[1] {
+		t.Errorf("expected a different JWT per renewal, got %q", jwts)
+	}
+}
+
+func TestParseAppPrivateKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := parseAppPrivateKey(string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})))
+	if err != nil || !parsed.Equal(key) {
+		t.Errorf("can't parse PKCS #8 key: %v", err)
+	}
+	if _, err := parseAppPrivateKey("not a key"); err == nil {
+		t.Error("expected error for invalid key")
+	}
+}
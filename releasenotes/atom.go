@@ -0,0 +1,63 @@
+package releasenotes
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"time"
+)
+
+// atomEntry is an entry of an Atom feed (RFC 4287)
+type atomEntry struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom entry"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Link    atomLink    `xml:"link"`
+	Updated string      `xml:"updated"`
+	Content atomContent `xml:"content"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	// Body is already escaped, so its line breaks are kept
+	Body string `xml:",innerxml"`
+}
+
+// AtomEntry returns the release as an Atom feed <entry>, so it can be added to a feed of
+// releases: the tag is the title, the link points to the compare view (or the release if there
+// is nothing to compare with), and the content contains the escaped notes
+func AtomEntry(config Options, result Result) (string, error) {
+	title := config.Tag
+	if title == "" {
+		title = shortSHA(result.Commit)
+	}
+	id := fmt.Sprintf("%s/%s/releases/tag/%s", serverURL(), config.Repository, title)
+	link := result.CompareURL
+	if link == "" {
+		link = id
+	}
+	date := result.ReleaseDate
+	if date == "" {
+		date = result.GenerationDate
+	}
+	updated, err := time.Parse(releaseDateLayout, date)
+	if err != nil {
+		return "", fmt.Errorf("invalid release date %q: %w", date, err)
+	}
+	entry, err := xml.MarshalIndent(atomEntry{
+		ID:      id,
+		Title:   title,
+		Link:    atomLink{Href: link},
+		Updated: updated.Format(time.RFC3339),
+		// the notes are markdown, so they are shown verbatim instead of interpreted as HTML
+		Content: atomContent{Type: "text", Body: html.EscapeString(result.Notes)},
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(entry), nil
+}
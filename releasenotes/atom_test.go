@@ -0,0 +1,51 @@
+package releasenotes
+
+import "testing"
+
+func TestAtomEntry(t *testing.T) {
+	t.Setenv("GITHUB_SERVER_URL", "")
+	result := Result{
+		Notes:  "## Changes from owner/repo:\n* Fix <br> & tags\n",
+		Commit: "0123456789abcdef",
+		ReleaseNotes: ReleaseNotes{
+			ReleaseDate:    "2024-06-01",
+			GenerationDate: "2024-06-03",
+			CompareURL:     "https://github.com/owner/repo/compare/aaa...bbb",
+		},
+	}
+	entry, err := AtomEntry(Options{Repository: "owner/repo", Tag: "v1.2.0"}, result)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `<entry xmlns="http://www.w3.org/2005/Atom">
+  <id>https://github.com/owner/repo/releases/tag/v1.2.0</id>
+  <title>v1.2.0</title>
+  <link href="https://github.com/owner/repo/compare/aaa...bbb"></link>
+  <updated>2024-06-01T00:00:00Z</updated>
+  <content type="text">## Changes from owner/repo:
+* Fix &lt;br&gt; &amp; tags
+</content>
+</entry>`
+	if entry != want {
+		t.Errorf("AtomEntry() = %s, want %s", entry, want)
+	}
+
+	// without tag, release date nor compare URL
+	result.ReleaseDate, result.CompareURL = "", ""
+	entry, err = AtomEntry(Options{Repository: "owner/repo"}, result)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = `<entry xmlns="http://www.w3.org/2005/Atom">
+  <id>https://github.com/owner/repo/releases/tag/0123456</id>
+  <title>0123456</title>
+  <link href="https://github.com/owner/repo/releases/tag/0123456"></link>
+  <updated>2024-06-03T00:00:00Z</updated>
+  <content type="text">## Changes from owner/repo:
+* Fix &lt;br&gt; &amp; tags
+</content>
+</entry>`
+	if entry != want {
+		t.Errorf("AtomEntry() = %s, want %s", entry, want)
+	}
+}
@@ -0,0 +1,58 @@
+package releasenotes
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// breakingChangesHeading is the heading of the section listing the breaking changes
+const breakingChangesHeading = "## ⚠️ Breaking Changes"
+
+// matches the conventional commit subjects that are marked as breaking, like "feat!: ..." or
+// "fix(api)!: ..."
+var breakingSubject = regexp.MustCompile(`^\w+(?:\([^)]*\))?!:`)
+
+// matches the BREAKING CHANGE footer of conventional commits, which is followed by its description
+var breakingFooter = regexp.MustCompile(`(?m)^BREAKING[ -]CHANGE:[ \t]*`)
+
+// breakingChange returns whether the change is marked as breaking in its subject or with a
+// BREAKING CHANGE footer in its body. The description is the paragraph of the footer, if any
+func breakingChange(c Change) (breaking bool, description string) {
+	loc := breakingFooter.FindStringIndex(c.Body)
+	if loc == nil {
+		return breakingSubject.MatchString(c.Subject), ""
+	}
+	paragraph, _, _ := strings.Cut(c.Body[loc[1]:], "\n\n")
+	return true, strings.Join(strings.Fields(paragraph), " ")
+}
+
+// renderBreakingChanges returns the section that lists the breaking changes of the main repository
+// and the submodules, with the description of their BREAKING CHANGE footer. It returns an empty
+// string if there are no breaking changes
+func renderBreakingChanges(config Options, rn ReleaseNotes) string {
+	changes := slices.Clone(rn.Changes)
+	for _, sm := range flattenSubmodules(rn.Submodules) {
+		changes = append(changes, sm.Changes...)
+	}
+	// the descriptions are rendered instead of the whole bodies
+	config.IncludeBody = false
+	var lines []string
+	for _, c := range changes {
+		breaking, description := breakingChange(c)
+		if !breaking {
+			continue
+		}
+		lines = append(lines, markdownList(config, []Change{c}))
+		if description != "" {
+			if config.EscapeMarkdown {
+				description = escapeMarkdown(description)
+			}
+			lines = append(lines, "  > "+description)
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return breakingChangesHeading + "\n" + strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,59 @@
+package releasenotes
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBreakingChange(t *testing.T) {
+	tests := []struct {
+		Change      Change
+		breaking    bool
+		description string
+	}{
+		{Change: Change{Subject: "feat: add option"}},
+		{Change: Change{Subject: "feat!: remove option"}, breaking: true},
+		{Change: Change{Subject: "fix(api)!: rename endpoint"}, breaking: true},
+		{Change: Change{Subject: "Revert \"feat!: remove option\""}},
+		{
+			Change:      Change{Subject: "feat: new config", Body: "Details\n\nBREAKING CHANGE: the old\nformat is not read\n\nRefs: #1"},
+			breaking:    true,
+			description: "the old format is not read",
+		},
+		{Change: Change{Subject: "refactor: cleanup", Body: "BREAKING-CHANGE: drops Go 1.22"}, breaking: true, description: "drops Go 1.22"},
+		{Change: Change{Subject: "docs: mention that a BREAKING CHANGE: is documented"}},
+	}
+	for _, tt := range tests {
+		breaking, description := breakingChange(tt.Change)
+		if breaking != tt.breaking || description != tt.description {
+			t.Errorf("breakingChange(%q) = %v, %q, want %v, %q",
+				tt.Change.Subject, breaking, description, tt.breaking, tt.description)
+		}
+	}
+}
+
+func TestRenderMarkdown_BreakingChanges(t *testing.T) {
+	config := Options{Repository: "owner/repo", BreakingChanges: true}
+	notes := ReleaseNotes{
+		Changes: []Change{
+			{Subject: "feat!: remove flag"},
+			{Subject: "fix: typo"},
+		},
+		Submodules: []*SubmoduleChanges{{Name: "lib", Repo: "org/lib", Changes: []Change{
+			{Subject: "feat: new API (org/lib#2)", Body: "BREAKING CHANGE: old API removed"},
+		}}},
+	}
+	want := "## ⚠️ Breaking Changes\n" +
+		"* feat!: remove flag\n" +
+		"* feat: new API (org/lib#2)\n" +
+		"  > old API removed\n\n" +
+		"## Changes from owner/repo:\n* feat!: remove flag\n* fix: typo\n"
+	if got := renderMarkdown(config, notes); !strings.HasPrefix(got, want) {
+		t.Errorf("renderMarkdown() = %q, want prefix %q", got, want)
+	}
+
+	config.BreakingChanges = false
+	if got := renderMarkdown(config, notes); !strings.HasPrefix(got, "## Changes") {
+		t.Errorf("expected no breaking changes section, got %q", got)
+	}
+}
@@ -0,0 +1,150 @@
+package releasenotes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// changesCache stores the changes between two commits in a directory, so repeated runs don't need
+// to query the API for the same comparison. A nil cache is valid and never hits.
+type changesCache struct {
+	dir string
+	ttl time.Duration
+	now func() time.Time
+}
+
+type cacheEntry struct {
+	Key     string    `json:"key"`
+	Created time.Time `json:"created"`
+	Changes []Change  `json:"changes"`
+	// Status is the status of the comparison, e.g. ahead or diverged
+	Status string `json:"status,omitempty"`
+}
+
+// newChangesCache returns nil if the directory is empty, which disables caching
+func newChangesCache(dir string, ttl time.Duration) *changesCache {
+	if dir == "" {
+		return nil
+	}
+	return &changesCache{dir: dir, ttl: ttl, now: time.Now}
+}
+
+// compareKey returns the cache key for the comparison of two commits of a repository
+func compareKey(owner, repo, base, head string) string {
+	return owner + "/" + repo + "/" + base + "..." + head
+}
+
+func (c *changesCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// get returns the cached changes for the key and the status of their comparison, if they exist
+// and have not expired
+func (c *changesCache) get(key string) ([]Change, string, bool) {
+	if c == nil {
+		return nil, "", false
+	}
+	content, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, "", false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(content, &entry); err != nil || entry.Key != key {
+		slog.Debug("ignoring invalid cache entry", "key", key, "error", err)
+		return nil, "", false
+	}
+	if c.ttl > 0 && c.now().Sub(entry.Created) > c.ttl {
+		slog.Debug("cache entry expired", "key", key, "created", entry.Created)
+		return nil, "", false
+	}
+	slog.Debug("cache hit", "key", key)
+	return entry.Changes, entry.Status, true
+}
+
+// submodulesCache memoizes, during a run, the submodules declared in the .gitmodules file of
+// each repository commit. A nil cache is valid and never hits.
+type submodulesCache struct {
+	mu      sync.Mutex
+	entries map[string][]gitSubmodule
+}
+
+func newSubmodulesCache() *submodulesCache {
+	return &submodulesCache{entries: map[string][]gitSubmodule{}}
+}
+
+// get returns a copy of the submodules of the repository commit, so callers can modify it
+func (c *submodulesCache) get(owner, repo, commit string) ([]gitSubmodule, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	submodules, ok := c.entries[owner+"/"+repo+"@"+commit]
+	return slices.Clone(submodules), ok
+}
+
+func (c *submodulesCache) put(owner, repo, commit string, submodules []gitSubmodule) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[owner+"/"+repo+"@"+commit] = slices.Clone(submodules)
+}
+
+// titlesCache memoizes, during a run, the titles of the issues and pull requests referenced by
+// the changes, indexed by owner/repo#number. The references that don't resolve are stored with an
+// empty title, so they aren't requested again. A nil cache is valid and never hits.
+type titlesCache struct {
+	mu      sync.Mutex
+	entries map[string]string
+}
+
+func newTitlesCache() *titlesCache {
+	return &titlesCache{entries: map[string]string{}}
+}
+
+func (c *titlesCache) get(owner, repo string, number int) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	title, ok := c.entries[fmt.Sprintf("%s/%s#%d", owner, repo, number)]
+	return title, ok
+}
+
+func (c *titlesCache) put(owner, repo string, number int, title string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[fmt.Sprintf("%s/%s#%d", owner, repo, number)] = title
+}
+
+// put stores the changes for the key and the status of their comparison. Failures are only
+// logged, since caching is optional
+func (c *changesCache) put(key string, changes []Change, status string) {
+	if c == nil {
+		return
+	}
+	content, err := json.Marshal(cacheEntry{Key: key, Created: c.now(), Changes: changes, Status: status})
+	if err == nil {
+		if err = os.MkdirAll(c.dir, 0o755); err == nil {
+			err = os.WriteFile(c.path(key), content, 0o644)
+		}
+	}
+	if err != nil {
+		slog.Warn("can't write cache entry", "key", key, "error", err)
+	}
+}
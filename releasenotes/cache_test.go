@@ -0,0 +1,115 @@
+package releasenotes
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChangesCache(t *testing.T) {
+	now := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	cache := newChangesCache(t.TempDir(), time.Hour)
+	cache.now = func() time.Time { return now }
+
+	key := compareKey("owner", "repo", "v1.0.0", "v1.1.0")
+	if _, _, ok := cache.get(key); ok {
+		t.Fatal("unexpected hit in empty cache")
+	}
+	changes := []Change{{Repo: "owner/repo", SHA: "abc", Subject: "Add feature", PR: 3}}
+	cache.put(key, changes, compareDiverged)
+
+	got, status, ok := cache.get(key)
+	if !ok || !reflect.DeepEqual(got, changes) || status != compareDiverged {
+		t.Errorf("get() = %+v, %q, %v, want %+v, %q, true", got, status, ok, changes, compareDiverged)
+	}
+	if _, _, ok := cache.get(compareKey("owner", "repo", "v1.0.0", "v1.2.0")); ok {
+		t.Error("unexpected hit for another key")
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, _, ok := cache.get(key); ok {
+		t.Error("unexpected hit for expired entry")
+	}
+}
+
+func TestGetChanges_Cached(t *testing.T) {
+	calls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/compare/old...new", func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		fmt.Fprint(w, `{"commits": [{"sha": "abc", "commit": {"message": "Add feature"}}]}`)
+	})
+	rnw := newTestWriter(t, Options{}, mux)
+	rnw.cache = newChangesCache(t.TempDir(), 0)
+
+	for range 2 {
+		changes, err := rnw.getChanges(t.Context(), "owner", "repo", "new", "old")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(changes) != 1 || changes[0].Subject != "Add feature" {
+			t.Errorf("unexpected changes: %+v", changes)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("got %d API calls, want 1", calls)
+	}
+}
+
+func TestGetChanges_CachedBySubjectMode(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/compare/old...new", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"commits": [{"sha": "abc", "commit": {"message": "Add feature\nwith details\n\nBody"}}]}`)
+	})
+	cache := newChangesCache(t.TempDir(), 0)
+	for _, tc := range []struct{ mode, subject string }{
+		{SubjectFirstLine, "Add feature"},
+		{SubjectParagraph, "Add feature with details"},
+		{SubjectFirstLine, "Add feature"},
+	} {
+		rnw := newTestWriter(t, Options{SubjectMode: tc.mode}, mux)
+		rnw.cache = cache
+		changes, err := rnw.getChanges(t.Context(), "owner", "repo", "new", "old")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(changes) != 1 || changes[0].Subject != tc.subject {
+			t.Errorf("subject mode %s: unexpected changes: %+v", tc.mode, changes)
+		}
+	}
+}
+
+func TestGetSubmodulePathRepo_Memoized(t *testing.T) {
+	requests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/contents/.gitmodules", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		gitmodulesHandler(`
+[submodule "lib"]
+	path = lib
+	url = https://github.com/org1/lib.git
+`)(w, r)
+	})
+	rnw := newTestWriter(t, Options{}, mux)
+	rnw.submodules = newSubmodulesCache()
+
+	for range 3 {
+		submodules, err := rnw.getSubmodulePathRepo(t.Context(), "owner", "repo", "abc")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(submodules) != 1 || submodules[0].Repo != "org1/lib" || submodules[0].Branch != "" {
+			t.Fatalf("unexpected submodules: %+v", submodules)
+		}
+		// modifying the result must not affect the memoized submodules
+		submodules[0].Branch = "modified"
+	}
+	if requests != 1 {
+		t.Errorf("expected .gitmodules to be fetched once, got %d requests", requests)
+	}
+	if _, err := rnw.getSubmodulePathRepo(t.Context(), "owner", "repo", "def"); err != nil || requests != 2 {
+		t.Errorf("expected another commit to be fetched, got %d requests, error %v", requests, err)
+	}
+}
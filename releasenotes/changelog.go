@@ -0,0 +1,83 @@
+package releasenotes
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// changelogTitle is the heading of the changelog files that are created by the changelog mode
+const changelogTitle = "# Changelog"
+
+// changelogAnchor matches the HTML anchor that starts each release section of the changelog
+var changelogAnchor = regexp.MustCompile(`^<a id="([^"]*)"></a>\s*$`)
+
+// UpdateChangelog writes the notes as the section of the version in the changelog file, which is
+// created if it does not exist
+func UpdateChangelog(path, version, notes string) error {
+	changelog, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("reading changelog: %w", err)
+	}
+	return os.WriteFile(path, []byte(mergeChangelog(string(changelog), version, notes)), 0644)
+}
+
+// mergeChangelog returns the changelog with the section of the version, which replaces the
+// existing section of the same version or, otherwise, is inserted before the first release
+// section, below the changelog title
+func mergeChangelog(changelog, version, notes string) string {
+	section := changelogSection(version, notes)
+	if strings.TrimSpace(changelog) == "" {
+		return changelogTitle + "\n\n" + section
+	}
+	lines := strings.SplitAfter(changelog, "\n")
+	if !strings.HasSuffix(changelog, "\n") {
+		lines[len(lines)-1] += "\n"
+	}
+	start, end := -1, len(lines)
+	for i, line := range lines {
+		m := changelogAnchor.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+		if m == nil {
+			continue
+		}
+		if start >= 0 {
+			end = i
+			break
+		}
+		if m[1] == html.EscapeString(version) {
+			start = i
+		}
+	}
+	if start < 0 {
+		// new version: it goes on top of the release sections, after the title and the text
+		// that precedes them
+		start = len(lines)
+		for i, line := range lines {
+			if changelogAnchor.MatchString(strings.TrimRight(line, "\r\n")) {
+				start = i
+				break
+			}
+		}
+		end = start
+		if start == len(lines) && lines[start-1] != "\n" {
+			section = "\n" + section
+		}
+	}
+	if end < len(lines) {
+		// blank line before the next section
+		section += "\n"
+	}
+	return strings.Join(lines[:start], "") + section + strings.Join(lines[end:], "")
+}
+
+// changelogSection returns the section of the version: an HTML anchor, so the release can be
+// linked as CHANGELOG.md#<version>, and a heading for the version followed by the notes, with
+// their headings demoted one level to nest under it
+func changelogSection(version, notes string) string {
+	return fmt.Sprintf("<a id=\"%s\"></a>\n## %s\n\n%s\n",
+		html.EscapeString(version), version, demoteHeadings(strings.TrimSpace(notes)))
+}
@@ -0,0 +1,76 @@
+package releasenotes
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeChangelog(t *testing.T) {
+	const notes = "## Changes from owner/repo:\n* Add feature\n"
+	const v2Section = "<a id=\"v1.2.0\"></a>\n## v1.2.0\n\n### Changes from owner/repo:\n* Fix crash\n"
+	tests := []struct {
+		name      string
+		changelog string
+		want      string
+	}{
+		{
+			name:      "empty",
+			changelog: "",
+			want:      "# Changelog\n\n<a id=\"v1.3.0\"></a>\n## v1.3.0\n\n### Changes from owner/repo:\n* Add feature\n",
+		},
+		{
+			name:      "only title",
+			changelog: "# Changelog\nAll notable changes.",
+			want: "# Changelog\nAll notable changes.\n\n" +
+				"<a id=\"v1.3.0\"></a>\n## v1.3.0\n\n### Changes from owner/repo:\n* Add feature\n",
+		},
+		{
+			name:      "new version on top",
+			changelog: "# Changelog\n\n" + v2Section,
+			want: "# Changelog\n\n" +
+				"<a id=\"v1.3.0\"></a>\n## v1.3.0\n\n### Changes from owner/repo:\n* Add feature\n\n" +
+				v2Section,
+		},
+		{
+			name: "existing version replaced in place",
+			changelog: "# Changelog\n\n" +
+				"<a id=\"v1.3.0\"></a>\n## v1.3.0\n\n### Changes from owner/repo:\n* Outdated\n* Entries\n\n" +
+				v2Section,
+			want: "# Changelog\n\n" +
+				"<a id=\"v1.3.0\"></a>\n## v1.3.0\n\n### Changes from owner/repo:\n* Add feature\n\n" +
+				v2Section,
+		},
+		{
+			name:      "last version replaced",
+			changelog: "# Changelog\n\n" + v2Section + "\n<a id=\"v1.3.0\"></a>\n## v1.3.0\n\n* Outdated\n",
+			want: "# Changelog\n\n" + v2Section +
+				"\n<a id=\"v1.3.0\"></a>\n## v1.3.0\n\n### Changes from owner/repo:\n* Add feature\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeChangelog(tt.changelog, "v1.3.0", notes); got != tt.want {
+				t.Errorf("mergeChangelog() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateChangelog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "CHANGELOG.md")
+	for _, notes := range []string{"* First run\n", "* Second run\n"} {
+		if err := UpdateChangelog(path, "v1.0.0", notes); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the second run replaces the section of the first one
+	want := "# Changelog\n\n<a id=\"v1.0.0\"></a>\n## v1.0.0\n\n* Second run\n"
+	if string(content) != want {
+		t.Errorf("changelog = %q, want %q", content, want)
+	}
+}
@@ -0,0 +1,74 @@
+package releasenotes
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/google/go-github/v57/github"
+)
+
+// commentBodyLimit is the maximum number of characters of a GitHub issue or pull request comment
+const commentBodyLimit = 65536
+
+// commentMarker identifies the preview comments, so they are updated instead of duplicated.
+// It's hidden in the rendered comment
+const commentMarker = "<!-- linked-release-notes preview -->"
+
+// CommentPullRequest posts the generated notes as a comment in the PRNumber pull request of the
+// Options, or updates the previous preview comment if it already exists. It returns the URL of
+// the comment
+func CommentPullRequest(ctx context.Context, client *github.Client, config Options, result Result) (string, error) {
+	owner, repo, _ := strings.Cut(config.Repository, "/")
+	rnw := &ReleaseNotesWriter{config: config, client: newGitHubAPI(client)}
+	return rnw.commentPullRequest(ctx, owner, repo, config.PRNumber, result.Notes, result.CompareURL)
+}
+
+// commentPullRequest posts the notes as a comment in the pull request, or updates the comment
+// that contains the commentMarker if it already exists. Notes that exceed the comment limit are
+// truncated, linking to the compareURL, if any. It returns the URL of the comment
+func (rnw *ReleaseNotesWriter) commentPullRequest(
+	ctx context.Context, owner, repo string, number int, notes, compareURL string,
+) (string, error) {
+	body := commentMarker + "\n" + truncateReleaseBody(notes, compareURL, commentBodyLimit-len(commentMarker)-1)
+	existing, err := rnw.findPreviewComment(ctx, owner, repo, number)
+	if err != nil {
+		return "", err
+	}
+	comment := &github.IssueComment{Body: &body}
+	if existing == nil {
+		created, _, err := rnw.client.Issues.CreateComment(ctx, owner, repo, number, comment)
+		if err != nil {
+			return "", fmt.Errorf("failed to comment pull request %d: %w", number, err)
+		}
+		slog.Info("preview comment created", "pr", number, "url", created.GetHTMLURL())
+		return created.GetHTMLURL(), nil
+	}
+	updated, _, err := rnw.client.Issues.EditComment(ctx, owner, repo, existing.GetID(), comment)
+	if err != nil {
+		return "", fmt.Errorf("failed to update comment %d: %w", existing.GetID(), err)
+	}
+	slog.Info("preview comment updated", "pr", number, "url", updated.GetHTMLURL())
+	return updated.GetHTMLURL(), nil
+}
+
+// findPreviewComment returns the comment of the pull request that contains the commentMarker,
+// or nil if there is none
+func (rnw *ReleaseNotesWriter) findPreviewComment(ctx context.Context, owner, repo string, number int) (*github.IssueComment, error) {
+	for page := 1; ; page++ {
+		comments, resp, err := rnw.client.Issues.ListComments(ctx, owner, repo, number,
+			&github.IssueListCommentsOptions{ListOptions: github.ListOptions{Page: page, PerPage: 100}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list comments of pull request %d: %w", number, err)
+		}
+		for _, comment := range comments {
+			if strings.HasPrefix(comment.GetBody(), commentMarker) {
+				return comment, nil
+			}
+		}
+		if page >= resp.LastPage {
+			return nil, nil
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package releasenotes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v57/github"
+)
+
+func TestCommentPullRequest_Create(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/issues/5/comments", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `[{"id": 1, "body": "LGTM, mentions `+commentMarker+`"}]`)
+	})
+	mux.HandleFunc("POST /repos/owner/repo/issues/5/comments", func(w http.ResponseWriter, r *http.Request) {
+		var comment github.IssueComment
+		if err := json.NewDecoder(r.Body).Decode(&comment); err != nil {
+			t.Fatal(err)
+		}
+		if want := commentMarker + "\nnotes"; comment.GetBody() != want {
+			t.Errorf("unexpected comment body: %q, want %q", comment.GetBody(), want)
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id": 2, "html_url": "https://github.com/owner/repo/pull/5#issuecomment-2"}`)
+	})
+	rnw := newTestWriter(t, Options{}, mux)
+
+	url, err := rnw.commentPullRequest(t.Context(), "owner", "repo", 5, "notes", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if url != "https://github.com/owner/repo/pull/5#issuecomment-2" {
+		t.Errorf("unexpected comment URL: %s", url)
+	}
+}
+
+func TestCommentPullRequest_UpdateExisting(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/issues/5/comments", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			body, _ := json.Marshal(commentMarker + "\nold notes")
+			fmt.Fprintf(w, `[{"id": 7, "body": %s}]`, body)
+			return
+		}
+		w.Header().Set("Link", `<`+r.URL.Path+`?page=2>; rel="last"`)
+		fmt.Fprint(w, `[{"id": 1, "body": "LGTM"}]`)
+	})
+	mux.HandleFunc("POST /repos/owner/repo/issues/5/comments", func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("an existing preview comment must not be created again")
+	})
+	mux.HandleFunc("PATCH /repos/owner/repo/issues/comments/7", func(w http.ResponseWriter, r *http.Request) {
+		var comment github.IssueComment
+		if err := json.NewDecoder(r.Body).Decode(&comment); err != nil {
+			t.Fatal(err)
+		}
+		if want := commentMarker + "\nnotes"; comment.GetBody() != want {
+			t.Errorf("unexpected comment body: %q, want %q", comment.GetBody(), want)
+		}
+		fmt.Fprint(w, `{"id": 7, "html_url": "https://github.com/owner/repo/pull/5#issuecomment-7"}`)
+	})
+	rnw := newTestWriter(t, Options{}, mux)
+
+	if _, err := rnw.commentPullRequest(t.Context(), "owner", "repo", 5, "notes", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
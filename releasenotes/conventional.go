@@ -0,0 +1,68 @@
+package releasenotes
+
+import (
+	"regexp"
+	"strings"
+)
+
+// layouts of the changes under each repository section
+const (
+	LayoutDefault      = "default"
+	LayoutRepoThenType = "repo-then-type"
+)
+
+// commitTypes are the headings of the conventional commit types, in rendering order. The changes
+// of any other type, or that are not conventional commits, go under otherChanges
+var commitTypes = []struct {
+	Type    string
+	Heading string
+}{
+	{"feat", "Features"},
+	{"fix", "Fixes"},
+	{"perf", "Performance"},
+	{"refactor", "Refactoring"},
+	{"docs", "Documentation"},
+	{"test", "Tests"},
+	{"build", "Build"},
+	{"ci", "CI"},
+	{"style", "Style"},
+	{"chore", "Chores"},
+	{"revert", "Reverts"},
+}
+
+// otherChanges is the heading of the changes without a known conventional commit type
+const otherChanges = "Other Changes"
+
+// matches the type of the conventional commit subjects, like "feat: ..." or "fix(api)!: ..."
+var commitType = regexp.MustCompile(`^(\w+)(?:\([^)]*\))?!?:`)
+
+// typeHeading returns the heading of the conventional commit type of the change
+func typeHeading(c Change) string {
+	if m := commitType.FindStringSubmatch(c.Subject); m != nil {
+		for _, ct := range commitTypes {
+			if strings.EqualFold(ct.Type, m[1]) {
+				return ct.Heading
+			}
+		}
+	}
+	return otherChanges
+}
+
+// groupByType groups the changes by their conventional commit type, in the order of commitTypes
+// followed by the other changes. Types without changes are omitted
+func groupByType(changes []Change) (headings []string, groups map[string][]Change) {
+	groups = map[string][]Change{}
+	for _, c := range changes {
+		heading := typeHeading(c)
+		groups[heading] = append(groups[heading], c)
+	}
+	for _, ct := range commitTypes {
+		if _, ok := groups[ct.Heading]; ok {
+			headings = append(headings, ct.Heading)
+		}
+	}
+	if _, ok := groups[otherChanges]; ok {
+		headings = append(headings, otherChanges)
+	}
+	return headings, groups
+}
@@ -0,0 +1,27 @@
+package releasenotes
+
+import "testing"
+
+func TestRenderMarkdown_RepoThenTypeLayout(t *testing.T) {
+	notes := renderMarkdown(Options{Repository: "owner/repo", Layout: LayoutRepoThenType}, ReleaseNotes{
+		Changes: []Change{
+			{Subject: "fix: crash on start"},
+			{Subject: "Update README"},
+			{Subject: "feat(api)!: new endpoint"},
+			{Subject: "FEAT: uppercase type"},
+			{Subject: "wip: unknown type"},
+		},
+		Submodules: []*SubmoduleChanges{{
+			Repo: "other/lib", State: SubmoduleUpdated,
+			Changes: []Change{{Subject: "fix: leak"}},
+		}},
+	})
+	want := "## Changes from owner/repo:\n" +
+		"### Features\n* feat(api)!: new endpoint\n* FEAT: uppercase type\n\n" +
+		"### Fixes\n* fix: crash on start\n\n" +
+		"### Other Changes\n* Update README\n* wip: unknown type\n\n" +
+		"## Changes from other/lib:\n### Fixes\n* fix: leak\n"
+	if notes != want {
+		t.Errorf("renderMarkdown() = %q, want %q", notes, want)
+	}
+}
@@ -0,0 +1,55 @@
+package releasenotes
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+)
+
+// maxComparisonFiles is the maximum number of files that the GitHub comparison API returns
+const maxComparisonFiles = 300
+
+// DiffStat is the aggregated size of the changes between two commits
+type DiffStat struct {
+	Files     int `json:"files"`
+	Additions int `json:"additions"`
+	Deletions int `json:"deletions"`
+}
+
+// diffStat returns the files changed, and the lines inserted and deleted, between prevCommit and
+// commit. The comparison API pages the commits, not the files: the files come only with the first
+// response and are capped at maxComparisonFiles, so the stat of a larger comparison is a lower
+// bound
+func (rnw *ReleaseNotesWriter) diffStat(ctx context.Context, owner, repo, commit, prevCommit string) (*DiffStat, error) {
+	comparison, _, err := rnw.client.Repositories.CompareCommits(ctx, owner, repo, prevCommit, commit, nil)
+	if err != nil {
+		return nil, err
+	}
+	stat := &DiffStat{}
+	for _, file := range comparison.Files {
+		stat.Files++
+		stat.Additions += file.GetAdditions()
+		stat.Deletions += file.GetDeletions()
+	}
+	if stat.Files >= maxComparisonFiles {
+		slog.Warn("the comparison returned the maximum number of files. The diffstat may be incomplete",
+			"repository", owner+"/"+repo, "previous", prevCommit, "commit", commit, "files", stat.Files)
+	}
+	return stat, nil
+}
+
+// String returns the diffstat line, e.g. "127 files changed, +3,400 -1,200"
+func (ds *DiffStat) String() string {
+	return fmt.Sprintf("%s changed, +%s -%s",
+		plural(ds.Files, "file", "files"), thousands(ds.Additions), thousands(ds.Deletions))
+}
+
+// thousands formats the number with commas as thousands separators
+func thousands(n int) string {
+	digits := strconv.Itoa(n)
+	for i := len(digits) - 3; i > 0; i -= 3 {
+		digits = digits[:i] + "," + digits[i:]
+	}
+	return digits
+}
@@ -0,0 +1,60 @@
+package releasenotes
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestDiffStat(t *testing.T) {
+	mux := http.NewServeMux()
+	calls := 0
+	mux.HandleFunc("GET /repos/owner/repo/compare/old...new", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		// the comparison has more pages of commits, but the files only come with the first one
+		w.Header().Set("Link", `<`+r.URL.Path+`?page=2>; rel="next", <`+r.URL.Path+`?page=2>; rel="last"`)
+		fmt.Fprint(w, `{"commits": [{"sha": "abc"}], "files": [
+			{"filename": "a.go", "additions": 3000, "deletions": 1000},
+			{"filename": "b.go", "additions": 399, "deletions": 0},
+			{"filename": "c.go", "additions": 1, "deletions": 200}]}`)
+	})
+	rnw := newTestWriter(t, Options{}, mux)
+
+	stat, err := rnw.diffStat(t.Context(), "owner", "repo", "new", "old")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := (DiffStat{Files: 3, Additions: 3400, Deletions: 1200}); *stat != want {
+		t.Errorf("diffStat() = %+v, want %+v", *stat, want)
+	}
+	if got, want := stat.String(), "3 files changed, +3,400 -1,200"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if calls != 1 {
+		t.Errorf("got %d API calls, want 1", calls)
+	}
+}
+
+func TestThousands(t *testing.T) {
+	for n, want := range map[int]string{0: "0", 999: "999", 1000: "1,000", 1234567: "1,234,567"} {
+		if got := thousands(n); got != want {
+			t.Errorf("thousands(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestRenderMarkdown_Diffstat(t *testing.T) {
+	notes := renderMarkdown(Options{Repository: "owner/repo"}, ReleaseNotes{
+		Changes:  []Change{{Subject: "Add feature"}},
+		DiffStat: &DiffStat{Files: 1, Additions: 10, Deletions: 2},
+		Submodules: []*SubmoduleChanges{{
+			Repo: "other/lib", State: SubmoduleUpdated, Old: "aaa", New: "bbb", Changes: []Change{{Subject: "Fix"}},
+			DiffStat: &DiffStat{Files: 127, Additions: 3400, Deletions: 1200},
+		}},
+	})
+	want := "## Changes from owner/repo:\n* Add feature\n\n> 1 file changed, +10 -2\n\n" +
+		"## Changes from other/lib:\n* Fix\n\n> 127 files changed, +3,400 -1,200\n"
+	if notes != want {
+		t.Errorf("renderMarkdown() = %q, want %q", notes, want)
+	}
+}
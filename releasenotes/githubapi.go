@@ -0,0 +1,62 @@
+package releasenotes
+
+import (
+	"context"
+
+	"github.com/google/go-github/v57/github"
+)
+
+// githubAPI groups the go-github services used to generate the release notes. Its fields are
+// named after those of github.Client, so the calls read the same, and are interfaces over the
+// methods in use, so the tests can replace them with fakes
+type githubAPI struct {
+	Repositories repositoriesAPI
+	Git          gitAPI
+	PullRequests pullRequestsAPI
+	Issues       issuesAPI
+}
+
+// newGitHubAPI returns the githubAPI that performs the requests with the given client
+func newGitHubAPI(client *github.Client) *githubAPI {
+	return &githubAPI{
+		Repositories: client.Repositories,
+		Git:          client.Git,
+		PullRequests: client.PullRequests,
+		Issues:       client.Issues,
+	}
+}
+
+// repositoriesAPI is the subset of github.RepositoriesService in use
+type repositoriesAPI interface {
+	CompareCommits(ctx context.Context, owner, repo string, base, head string, opts *github.ListOptions) (*github.CommitsComparison, *github.Response, error)
+	CreateRelease(ctx context.Context, owner, repo string, release *github.RepositoryRelease) (*github.RepositoryRelease, *github.Response, error)
+	EditRelease(ctx context.Context, owner, repo string, id int64, release *github.RepositoryRelease) (*github.RepositoryRelease, *github.Response, error)
+	GenerateReleaseNotes(ctx context.Context, owner, repo string, opts *github.GenerateNotesOptions) (*github.RepositoryReleaseNotes, *github.Response, error)
+	Get(ctx context.Context, owner, repo string) (*github.Repository, *github.Response, error)
+	GetCommit(ctx context.Context, owner, repo, sha string, opts *github.ListOptions) (*github.RepositoryCommit, *github.Response, error)
+	GetContents(ctx context.Context, owner, repo, path string, opts *github.RepositoryContentGetOptions) (*github.RepositoryContent, []*github.RepositoryContent, *github.Response, error)
+	GetReleaseByTag(ctx context.Context, owner, repo, tag string) (*github.RepositoryRelease, *github.Response, error)
+	ListCommits(ctx context.Context, owner, repo string, opts *github.CommitsListOptions) ([]*github.RepositoryCommit, *github.Response, error)
+	ListReleases(ctx context.Context, owner, repo string, opts *github.ListOptions) ([]*github.RepositoryRelease, *github.Response, error)
+	ListTags(ctx context.Context, owner, repo string, opts *github.ListOptions) ([]*github.RepositoryTag, *github.Response, error)
+}
+
+// gitAPI is the subset of github.GitService in use
+type gitAPI interface {
+	GetCommit(ctx context.Context, owner, repo, sha string) (*github.Commit, *github.Response, error)
+	GetRef(ctx context.Context, owner, repo, ref string) (*github.Reference, *github.Response, error)
+	GetTree(ctx context.Context, owner, repo, sha string, recursive bool) (*github.Tree, *github.Response, error)
+}
+
+// pullRequestsAPI is the subset of github.PullRequestsService in use
+type pullRequestsAPI interface {
+	ListPullRequestsWithCommit(ctx context.Context, owner, repo, sha string, opts *github.ListOptions) ([]*github.PullRequest, *github.Response, error)
+}
+
+// issuesAPI is the subset of github.IssuesService in use
+type issuesAPI interface {
+	CreateComment(ctx context.Context, owner, repo string, number int, comment *github.IssueComment) (*github.IssueComment, *github.Response, error)
+	EditComment(ctx context.Context, owner, repo string, commentID int64, comment *github.IssueComment) (*github.IssueComment, *github.Response, error)
+	Get(ctx context.Context, owner, repo string, number int) (*github.Issue, *github.Response, error)
+	ListComments(ctx context.Context, owner, repo string, number int, opts *github.IssueListCommentsOptions) ([]*github.IssueComment, *github.Response, error)
+}
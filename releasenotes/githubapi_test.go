@@ -0,0 +1,206 @@
+package releasenotes
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/v57/github"
+)
+
+// fakeRepositories serves the releases and the comparisons of a repository from memory. The
+// methods that aren't overridden panic, as the embedded interface is nil
+type fakeRepositories struct {
+	repositoriesAPI
+	// releasePages are the pages of releases, in the order they are listed
+	releasePages [][]*github.RepositoryRelease
+	// comparisons are indexed by "base...head"
+	comparisons map[string]*github.CommitsComparison
+	compared    []string
+}
+
+func (f *fakeRepositories) ListReleases(
+	_ context.Context, _, _ string, opts *github.ListOptions,
+) ([]*github.RepositoryRelease, *github.Response, error) {
+	page := max(opts.Page, 1)
+	resp := &github.Response{LastPage: len(f.releasePages)}
+	if page > len(f.releasePages) {
+		return nil, resp, nil
+	}
+	return f.releasePages[page-1], resp, nil
+}
+
+func (f *fakeRepositories) CompareCommits(
+	_ context.Context, owner, repo, base, head string, _ *github.ListOptions,
+) (*github.CommitsComparison, *github.Response, error) {
+	f.compared = append(f.compared, base+"..."+head)
+	comparison, ok := f.comparisons[base+"..."+head]
+	if !ok {
+		return nil, nil, fmt.Errorf("unexpected comparison of %s/%s: %s...%s", owner, repo, base, head)
+	}
+	return comparison, &github.Response{}, nil
+}
+
+// fakeGit serves the trees of a repository from memory
+type fakeGit struct {
+	gitAPI
+	// trees are the paths of the gitlinks of each commit, with the commit they point to
+	trees map[string]map[string]string
+}
+
+func (f *fakeGit) GetTree(_ context.Context, _, _, sha string, _ bool) (*github.Tree, *github.Response, error) {
+	gitlinks, ok := f.trees[sha]
+	if !ok {
+		return nil, nil, fmt.Errorf("unexpected tree %s", sha)
+	}
+	tree := &github.Tree{SHA: github.String(sha)}
+	for path, commit := range gitlinks {
+		tree.Entries = append(tree.Entries, &github.TreeEntry{
+			Path: github.String(path), Type: github.String("commit"), SHA: github.String(commit),
+		})
+	}
+	return tree, &github.Response{}, nil
+}
+
+func releases(tags ...string) []*github.RepositoryRelease {
+	var result []*github.RepositoryRelease
+	for _, tag := range tags {
+		result = append(result, &github.RepositoryRelease{TagName: github.String(tag)})
+	}
+	return result
+}
+
+func repositoryCommit(sha, message, login string) *github.RepositoryCommit {
+	return &github.RepositoryCommit{
+		SHA:    github.String(sha),
+		Author: &github.User{Login: github.String(login)},
+		Commit: &github.Commit{Message: github.String(message)},
+	}
+}
+
+func TestFetchPreviousTag_FakeAPI(t *testing.T) {
+	repos := &fakeRepositories{releasePages: [][]*github.RepositoryRelease{
+		append(releases("v2.0.0", "v1.2.5"), &github.RepositoryRelease{
+			TagName: github.String("v2.1.0"), Prerelease: github.Bool(true),
+		}),
+		releases("v1.3.0", "v2.1.0-rc.1", "v1.2.0"),
+	}}
+	tests := []struct {
+		tag, since string
+		tagsBack   int
+		want       string
+	}{
+		{tag: "v2.1.0", want: "v2.0.0"},
+		{tag: "v2.0.0", want: "v1.3.0"},
+		{tag: "v2.0.0", tagsBack: 2, want: "v1.2.5"},
+		{tag: "v1.3.0", want: "v1.2.5"},
+		// the prereleases are only candidates when the previous tag can be any release
+		{tag: "v2.2.0", want: "v2.0.0"},
+		{tag: "v2.2.0", since: SinceLastRelease, want: "v2.1.0"},
+		// with no older release, the newest one is used
+		{tag: "v1.0.0", want: "v2.0.0"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s-%s-%d", tt.tag, tt.since, tt.tagsBack), func(t *testing.T) {
+			rnw := &ReleaseNotesWriter{
+				config: Options{Tag: tt.tag, Since: tt.since, TagsBack: tt.tagsBack},
+				client: &githubAPI{Repositories: repos},
+			}
+			if err := rnw.fetchPreviousTag(t.Context(), "owner", "repo"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if rnw.previousTag != tt.want {
+				t.Errorf("previous tag = %q, want %q", rnw.previousTag, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetChanges_FakeAPI(t *testing.T) {
+	comparison := &github.CommitsComparison{
+		Status: github.String("ahead"),
+		Commits: []*github.RepositoryCommit{
+			repositoryCommit("c1", "Add parser (#12)\n\nParses the input", "alice"),
+			repositoryCommit("c2", "Fix crash\nin the parser", "bob"),
+			// commits without message are skipped
+			{SHA: github.String("c3")},
+		},
+	}
+	repos := &fakeRepositories{comparisons: map[string]*github.CommitsComparison{"old...new": comparison}}
+	rnw := &ReleaseNotesWriter{
+		config: Options{PRSuffix: PRSuffixKeep, SubjectMode: SubjectFirstLine},
+		client: &githubAPI{Repositories: repos},
+	}
+
+	changes, err := rnw.getChanges(t.Context(), "owner", "repo", "new", "old")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []Change{
+		{Repo: "owner/repo", SHA: "c1", Subject: "Add parser (#12)", Body: "Parses the input", Author: "alice", PR: 12},
+		{Repo: "owner/repo", SHA: "c2", Subject: "Fix crash", Body: "in the parser", Author: "bob"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("getChanges() = %+v, want %+v", changes, want)
+	}
+}
+
+func TestGetChanges_FakeAPIMergeBase(t *testing.T) {
+	repos := &fakeRepositories{comparisons: map[string]*github.CommitsComparison{
+		"old...new": {
+			Status:          github.String("diverged"),
+			MergeBaseCommit: &github.RepositoryCommit{SHA: github.String("base")},
+			Commits:         []*github.RepositoryCommit{repositoryCommit("c1", "Divergent", "alice")},
+		},
+		"base...new": {
+			Commits: []*github.RepositoryCommit{repositoryCommit("c2", "Since the merge-base", "bob")},
+		},
+	}}
+	rnw := &ReleaseNotesWriter{
+		config: Options{UseMergeBase: true, PRSuffix: PRSuffixKeep, SubjectMode: SubjectFirstLine},
+		client: &githubAPI{Repositories: repos},
+	}
+
+	changes, err := rnw.getChanges(t.Context(), "owner", "repo", "new", "old")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(changes) != 1 || changes[0].SHA != "c2" {
+		t.Errorf("unexpected changes: %+v", changes)
+	}
+	if want := []string{"old...new", "base...new"}; !reflect.DeepEqual(repos.compared, want) {
+		t.Errorf("compared %q, want %q", repos.compared, want)
+	}
+}
+
+func TestGetSubmoduleCommits_FakeAPI(t *testing.T) {
+	git := &fakeGit{trees: map[string]map[string]string{
+		"v1": {"lib": "lib1", "tool": "tool1"},
+		"v2": {"lib": "lib2", "tool": "tool1", "new": "new1"},
+	}}
+	rnw := &ReleaseNotesWriter{client: &githubAPI{Git: git}}
+	src := githubSource{rnw: rnw, owner: "org", repo: "lib"}
+
+	tests := map[string]submoduleCommits{
+		"lib":  {Old: "lib1", New: "lib2", State: SubmoduleUpdated},
+		"tool": {Old: "tool1", New: "tool1", State: SubmoduleUpdated},
+		"new":  {New: "new1", State: SubmoduleAdded},
+	}
+	for path, want := range tests {
+		sc, err := rnw.getSubmoduleCommits(t.Context(), "owner", "repo", "v1", "v2", gitSubmodule{Path: path}, src)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", path, err)
+		}
+		if sc != want {
+			t.Errorf("%s: getSubmoduleCommits() = %+v, want %+v", path, sc, want)
+		}
+	}
+	sc, err := rnw.getSubmoduleCommits(t.Context(), "owner", "repo", "v2", "v1", gitSubmodule{Path: "new"}, src)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := (submoduleCommits{Old: "new1", State: SubmoduleRemoved}); sc != want {
+		t.Errorf("getSubmoduleCommits() = %+v, want %+v", sc, want)
+	}
+}
@@ -0,0 +1,217 @@
+package releasenotes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const gitlabHost = "gitlab.com"
+
+// gitlabClient is a minimal client for the GitLab REST API, covering the calls that are required
+// to get the changes of the submodules hosted in GitLab
+type gitlabClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func newGitLabClient(host, token string, httpClient *http.Client) *gitlabClient {
+	return &gitlabClient{baseURL: "https://" + host + "/api/v4", token: token, http: httpClient}
+}
+
+// gitlabCommit is the subset of the GitLab commit fields that are used for the release notes
+type gitlabCommit struct {
+	ID           string    `json:"id"`
+	Title        string    `json:"title"`
+	Message      string    `json:"message"`
+	AuthorName   string    `json:"author_name"`
+	AuthoredDate time.Time `json:"authored_date"`
+}
+
+// gitlabError is returned for any non-2xx response from the GitLab API
+type gitlabError struct {
+	StatusCode int
+	URL        string
+}
+
+func (e *gitlabError) Error() string {
+	return fmt.Sprintf("GET %s: %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// get sends a GET request to the given API path and decodes the JSON response into dst.
+// It returns the X-Next-Page header, which is empty in the last page
+func (gc *gitlabClient) get(ctx context.Context, path string, query url.Values, dst any) (string, error) {
+	u := gc.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	if gc.token != "" {
+		req.Header.Set("PRIVATE-TOKEN", gc.token)
+	}
+	resp, err := gc.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", &gitlabError{StatusCode: resp.StatusCode, URL: u}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(dst); err != nil {
+		return "", fmt.Errorf("decoding %s response: %w", u, err)
+	}
+	return resp.Header.Get("X-Next-Page"), nil
+}
+
+// projectPath returns the API path prefix for the project with the given full path (e.g. group/repo)
+func projectPath(project string) string {
+	return "/projects/" + url.PathEscape(project)
+}
+
+// compare returns the commits that are reachable from commit but not from prevCommit, oldest first
+func (gc *gitlabClient) compare(ctx context.Context, project, commit, prevCommit string) ([]gitlabCommit, error) {
+	var comparison struct {
+		Commits []gitlabCommit `json:"commits"`
+	}
+	query := url.Values{"from": {prevCommit}, "to": {commit}, "straight": {"false"}}
+	if _, err := gc.get(ctx, projectPath(project)+"/repository/compare", query, &comparison); err != nil {
+		return nil, err
+	}
+	return comparison.Commits, nil
+}
+
+// history returns all the commits that are reachable from the given commit, newest first
+func (gc *gitlabClient) history(ctx context.Context, project, commit string) ([]gitlabCommit, error) {
+	var commits []gitlabCommit
+	for page := "1"; page != ""; {
+		var pageCommits []gitlabCommit
+		query := url.Values{"ref_name": {commit}, "per_page": {"100"}, "page": {page}}
+		next, err := gc.get(ctx, projectPath(project)+"/repository/commits", query, &pageCommits)
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, pageCommits...)
+		page = next
+	}
+	return commits, nil
+}
+
+// branchHead returns the SHA of the last commit in the given branch
+func (gc *gitlabClient) branchHead(ctx context.Context, project, branch string) (string, error) {
+	var b struct {
+		Commit gitlabCommit `json:"commit"`
+	}
+	if _, err := gc.get(ctx, projectPath(project)+"/repository/branches/"+url.PathEscape(branch), nil, &b); err != nil {
+		return "", err
+	}
+	return b.Commit.ID, nil
+}
+
+// tagsByCommit returns the tag names of the project, indexed by the SHA of the commit they point to
+func (gc *gitlabClient) tagsByCommit(ctx context.Context, project string) (map[string]string, error) {
+	tags := map[string]string{}
+	for page := "1"; page != ""; {
+		var pageTags []struct {
+			Name   string       `json:"name"`
+			Commit gitlabCommit `json:"commit"`
+		}
+		query := url.Values{"per_page": {"100"}, "page": {page}}
+		next, err := gc.get(ctx, projectPath(project)+"/repository/tags", query, &pageTags)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range pageTags {
+			if _, ok := tags[tag.Commit.ID]; !ok && tag.Commit.ID != "" {
+				tags[tag.Commit.ID] = tag.Name
+			}
+		}
+		page = next
+	}
+	return tags, nil
+}
+
+// commitFiles returns the old and new paths of the files modified by the commit
+func (gc *gitlabClient) commitFiles(ctx context.Context, project, sha string) ([]string, error) {
+	var files []string
+	for page := "1"; page != ""; {
+		var diffs []struct {
+			OldPath string `json:"old_path"`
+			NewPath string `json:"new_path"`
+		}
+		query := url.Values{"per_page": {"100"}, "page": {page}}
+		next, err := gc.get(ctx, projectPath(project)+"/repository/commits/"+url.PathEscape(sha)+"/diff", query, &diffs)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range diffs {
+			files = append(files, d.NewPath)
+			if d.OldPath != d.NewPath {
+				files = append(files, d.OldPath)
+			}
+		}
+		page = next
+	}
+	return files, nil
+}
+
+// gitlabChanges returns a release notes entry for each GitLab commit, whose subject is extracted
+// from the commit message according to the subjectMode
+func gitlabChanges(project, subjectMode string, commits []gitlabCommit) []Change {
+	changes := make([]Change, 0, len(commits))
+	for _, c := range commits {
+		message := c.Message
+		if message == "" {
+			message = c.Title
+		}
+		ch := Change{Repo: project, SHA: c.ID, Subject: commitSubject(message, subjectMode), Author: c.AuthorName, Date: c.AuthoredDate}
+		ch.Body, ch.CoAuthors = commitBody(message, subjectMode)
+		changes = append(changes, ch)
+	}
+	return changes
+}
+
+// gitlabSource provides the changes of a submodule hosted in GitLab
+type gitlabSource struct {
+	client      *gitlabClient
+	project     string
+	subjectMode string
+}
+
+func (s gitlabSource) changes(ctx context.Context, commit, prevCommit string) ([]Change, error) {
+	commits, err := s.client.compare(ctx, s.project, commit, prevCommit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare commits: %w", err)
+	}
+	return gitlabChanges(s.project, s.subjectMode, commits), nil
+}
+
+func (s gitlabSource) changesUpTo(ctx context.Context, commit string) ([]Change, error) {
+	commits, err := s.client.history(ctx, s.project, commit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits: %w", err)
+	}
+	return gitlabChanges(s.project, s.subjectMode, commits), nil
+}
+
+func (s gitlabSource) branchHead(ctx context.Context, branch string) (string, error) {
+	return s.client.branchHead(ctx, s.project, branch)
+}
+
+func (s gitlabSource) commitFiles(ctx context.Context, sha string) ([]string, error) {
+	return s.client.commitFiles(ctx, s.project, sha)
+}
+
+func (s gitlabSource) tagsByCommit(ctx context.Context) (map[string]string, error) {
+	tags, err := s.client.tagsByCommit(ctx, s.project)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	return tags, nil
+}
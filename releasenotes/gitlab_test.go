@@ -0,0 +1,137 @@
+package releasenotes
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestGetChangesForSubmodules_GitLab(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/contents/.gitmodules", gitmodulesHandler(`
+[submodule "lib"]
+	path = lib
+	url = https://gitlab.com/group/lib.git
+`))
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tree": [{"path": "lib", "type": "commit", "sha": "lib-%s"}]}`, r.PathValue("sha"))
+	})
+	rnw := newTestWriter(t, Options{}, mux)
+
+	glMux := http.NewServeMux()
+	glMux.HandleFunc("GET /api/v4/projects/group%2Flib/repository/compare", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "gl-token" {
+			t.Errorf("unexpected token: %q", r.Header.Get("PRIVATE-TOKEN"))
+		}
+		if q := r.URL.Query(); q.Get("from") != "lib-old" || q.Get("to") != "lib-new" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `{"commits": [
+			{"id": "g1", "title": "Fix parser (#3)", "author_name": "Someone"},
+			{"id": "g2", "title": "Update docs", "author_name": "Somebody"}
+		]}`)
+	})
+	glMux.HandleFunc("GET /api/v4/projects/group%2Flib/repository/tags", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `[{"name": "v1.3.0", "commit": {"id": "lib-new"}}, {"name": "v1.2.0", "commit": {"id": "other"}}]`)
+	})
+	glSrv := httptest.NewServer(glMux)
+	t.Cleanup(glSrv.Close)
+	rnw.gitlab = &gitlabClient{baseURL: glSrv.URL + "/api/v4", token: "gl-token", http: glSrv.Client()}
+
+	smChanges, err := rnw.getChangesForSubmodules(t.Context(), "owner", "repo", "new", "old")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(smChanges) != 1 {
+		t.Fatalf("got %d submodules, want 1", len(smChanges))
+	}
+	if smChanges[0].OldTag != "" || smChanges[0].NewTag != "v1.3.0" {
+		t.Errorf("unexpected tags: %q -> %q", smChanges[0].OldTag, smChanges[0].NewTag)
+	}
+	want := []Change{
+		{Repo: "group/lib", SHA: "g1", Subject: "Fix parser ([group/lib#3](https://gitlab.com/group/lib/-/issues/3))", Author: "Someone"},
+		{Repo: "group/lib", SHA: "g2", Subject: "Update docs", Author: "Somebody"},
+	}
+	if !reflect.DeepEqual(smChanges[0].Changes, want) {
+		t.Errorf("changes = %+v, want %+v", smChanges[0].Changes, want)
+	}
+}
+
+func TestGetChangesForSubmodules_GitLabFilters(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/contents/.gitmodules", gitmodulesHandler(`
+[submodule "lib"]
+	path = lib
+	url = https://gitlab.com/group/lib.git
+`))
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tree": [{"path": "lib", "type": "commit", "sha": "lib-%s"}]}`, r.PathValue("sha"))
+	})
+	rnw := newTestWriter(t, Options{
+		IncludePattern:       "^(feat|fix|Revert)",
+		ExcludePattern:       "WIP",
+		ExcludeCommitAuthors: []string{"bot"},
+		PRSuffix:             PRSuffixStrip,
+		CollapseReverts:      true,
+		SubjectMode:          SubjectParagraph,
+	}, mux)
+
+	glMux := http.NewServeMux()
+	glMux.HandleFunc("GET /api/v4/projects/group%2Flib/repository/compare", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"commits": [
+			{"id": "g1", "title": "fix: parser", "message": "fix: parser\nwith nested lists (#3)\n\nDetails", "author_name": "Someone"},
+			{"id": "g2", "title": "docs: typo", "message": "docs: typo", "author_name": "Someone"},
+			{"id": "g3", "title": "feat: WIP cache", "message": "feat: WIP cache", "author_name": "Someone"},
+			{"id": "g4", "title": "feat: bump deps", "message": "feat: bump deps", "author_name": "bot"},
+			{"id": "g5", "title": "feat: tables", "message": "feat: tables", "author_name": "Someone"},
+			{"id": "g6", "title": "Revert \"feat: tables\"", "message": "Revert \"feat: tables\"", "author_name": "Someone"}
+		]}`)
+	})
+	glMux.HandleFunc("GET /api/v4/projects/group%2Flib/repository/tags", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `[]`)
+	})
+	glSrv := httptest.NewServer(glMux)
+	t.Cleanup(glSrv.Close)
+	rnw.gitlab = &gitlabClient{baseURL: glSrv.URL + "/api/v4", http: glSrv.Client()}
+
+	smChanges, err := rnw.getChangesForSubmodules(t.Context(), "owner", "repo", "new", "old")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(smChanges) != 1 || smChanges[0].Err != nil {
+		t.Fatalf("unexpected submodules: %+v", smChanges)
+	}
+	want := []Change{
+		{Repo: "group/lib", SHA: "g1", Subject: "fix: parser with nested lists", Author: "Someone", Body: "Details"},
+	}
+	if !reflect.DeepEqual(smChanges[0].Changes, want) {
+		t.Errorf("changes = %+v, want %+v", smChanges[0].Changes, want)
+	}
+}
+
+func TestGitLabClient_HistoryPagination(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v4/projects/group%2Flib/repository/commits", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("page") {
+		case "1":
+			w.Header().Set("X-Next-Page", "2")
+			fmt.Fprint(w, `[{"id": "c3", "title": "Third"}, {"id": "c2", "title": "Second"}]`)
+		default:
+			fmt.Fprint(w, `[{"id": "c1", "title": "First"}]`)
+		}
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	gc := &gitlabClient{baseURL: srv.URL + "/api/v4", http: srv.Client()}
+
+	commits, err := gc.history(t.Context(), "group/lib", "c3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []gitlabCommit{{ID: "c3", Title: "Third"}, {ID: "c2", Title: "Second"}, {ID: "c1", Title: "First"}}
+	if !reflect.DeepEqual(commits, want) {
+		t.Errorf("history() = %+v, want %+v", commits, want)
+	}
+}
@@ -0,0 +1,176 @@
+package releasenotes
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// gitSubmodule is a submodule entry as declared in a .gitmodules file
+type gitSubmodule struct {
+	Name string
+	Path string
+	URL  string
+	// Host and Repo are the host and the owner/repo name resolved from the URL, or empty if
+	// the URL format is not recognized, as described by URLErr
+	Host   string
+	Repo   string
+	URLErr error
+	// Branch is the branch of the submodule repository that is tracked by the main repository,
+	// if any
+	Branch string
+	// Pin is the commit of a dependency pinned in the SubmoduleLockfile, instead of a gitlink
+	Pin string
+	// PreviousHost and PreviousRepo are the repository that the submodule was fetched from in the
+	// previous commit, if it has moved since then (e.g. renamed, transferred or forked)
+	PreviousHost string
+	PreviousRepo string
+}
+
+// githubHost is the host of the submodules read from the GitHub API when their URL is just an
+// owner/repo name, e.g. in the SubmoduleLockfile
+const githubHost = "github.com"
+
+// onGitHub returns whether the submodule repository is read from the GitHub API. Submodules that
+// are not hosted in GitLab are considered GitHub repositories
+func (sm gitSubmodule) onGitHub() bool {
+	return sm.Host != gitlabHost
+}
+
+// parseGitmodules parses the contents of a .gitmodules file, which follows the git-config
+// INI-style syntax: [submodule "name"] section headers followed by key = value lines.
+// Keys are case-insensitive, values can be quoted, and anything after an unquoted # or ;
+// is a comment. Keys outside a submodule section are ignored.
+// Submodules are returned in the same order as they are declared in the file.
+func parseGitmodules(content string) []gitSubmodule {
+	var submodules []gitSubmodule
+	var current *gitSubmodule
+	for _, line := range strings.Split(content, "\n") {
+		// trimming also removes the \r of the files with Windows (CRLF) line endings
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' {
+			current = nil
+			if name, ok := parseSubmoduleHeader(line); ok {
+				submodules = append(submodules, gitSubmodule{Name: name})
+				current = &submodules[len(submodules)-1]
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		key, value, _ := strings.Cut(line, "=")
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "path":
+			current.Path = parseGitConfigValue(value)
+		case "url":
+			current.URL = parseGitConfigValue(value)
+		case "branch":
+			current.Branch = parseGitConfigValue(value)
+		}
+	}
+	for i := range submodules {
+		submodules[i].Host, submodules[i].Repo, submodules[i].URLErr = parseSubmoduleURL(submodules[i].URL)
+	}
+	return submodules
+}
+
+// parseSubmoduleHeader returns the submodule name from a [submodule "name"] section header.
+// It returns false if the line is the header of any other section.
+func parseSubmoduleHeader(line string) (string, bool) {
+	end := strings.LastIndexByte(line, ']')
+	if end < 0 {
+		return "", false
+	}
+	section, subsection, _ := strings.Cut(strings.TrimSpace(line[1:end]), " ")
+	if !strings.EqualFold(section, "submodule") {
+		return "", false
+	}
+	return parseGitConfigValue(subsection), true
+}
+
+// parseGitConfigValue unquotes a git-config value, removing any trailing comment and the
+// whitespace surrounding unquoted parts of the value
+func parseGitConfigValue(raw string) string {
+	var sb strings.Builder
+	quoted, escaped := false, false
+	for _, r := range strings.TrimSpace(raw) {
+		switch {
+		case escaped:
+			switch r {
+			case 't':
+				sb.WriteRune('\t')
+			case 'n':
+				sb.WriteRune('\n')
+			default:
+				sb.WriteRune(r)
+			}
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case !quoted && (r == '#' || r == ';'):
+			return strings.TrimSpace(sb.String())
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// errRelativeSubmoduleURL is returned for the submodule URLs that are relative to the remote of
+// the main repository (e.g. ../lib.git), which can't be resolved from the .gitmodules file alone
+var errRelativeSubmoduleURL = errors.New("relative submodule URLs are not supported")
+
+// parseSubmoduleURL extracts the host and the owner/repo name from a submodule URL: an http(s)://,
+// ssh:// or git:// URL, or an scp-like [user@]host:path address. For hosts other than GitHub, the
+// repo is the full project path, as GitLab projects can be nested in subgroups (e.g.
+// group/subgroup/repo). The trailing slashes and .git suffix of the path are ignored
+func parseSubmoduleURL(rawURL string) (host, ownerRepo string, err error) {
+	var path string
+	switch {
+	case strings.Contains(rawURL, "://"):
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid submodule URL: %w", err)
+		}
+		switch u.Scheme {
+		case "http", "https", "ssh", "git":
+		default:
+			return "", "", fmt.Errorf("unsupported submodule URL scheme %q", u.Scheme)
+		}
+		host, path = u.Hostname(), u.Path
+	case isSCPLike(rawURL):
+		address, p, _ := strings.Cut(rawURL, ":")
+		if _, h, ok := strings.Cut(address, "@"); ok {
+			address = h
+		}
+		host, path = address, p
+	default:
+		return "", "", fmt.Errorf("%w: %q", errRelativeSubmoduleURL, rawURL)
+	}
+	host = strings.ToLower(host)
+	path = strings.Trim(strings.TrimSuffix(strings.TrimRight(path, "/"), ".git"), "/")
+	segments := strings.Split(path, "/")
+	if host == "" || len(segments) < 2 ||
+		slices.ContainsFunc(segments, func(s string) bool { return s == "" || s == "." || s == ".." }) {
+		return "", "", fmt.Errorf("submodule URL %q has no host and owner/repo path", rawURL)
+	}
+	if host == githubHost && len(segments) != 2 {
+		return "", "", fmt.Errorf("submodule URL %q is not a GitHub owner/repo path", rawURL)
+	}
+	return host, path, nil
+}
+
+// isSCPLike returns whether the URL has the scp-like [user@]host:path syntax, which git assumes
+// when there is a colon and no slash before it
+func isSCPLike(rawURL string) bool {
+	colon := strings.IndexByte(rawURL, ':')
+	return colon > 0 && !strings.Contains(rawURL[:colon], "/")
+}
@@ -0,0 +1,65 @@
+package releasenotes
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// groupRule assigns the Section to the changes whose subject matches the Pattern
+type groupRule struct {
+	Pattern string `yaml:"pattern"`
+	Section string `yaml:"section"`
+	re      *regexp.Regexp
+}
+
+// parseGroupRules parses the GroupRules option: a YAML (or JSON) list of {pattern, section}
+// objects, in priority order
+func parseGroupRules(rules string) ([]groupRule, error) {
+	var parsed []groupRule
+	if err := yaml.Unmarshal([]byte(rules), &parsed); err != nil {
+		return nil, err
+	}
+	if len(parsed) == 0 {
+		return nil, errors.New("no rules found")
+	}
+	for i := range parsed {
+		r := &parsed[i]
+		if r.Pattern == "" || r.Section == "" {
+			return nil, fmt.Errorf("rule %d must have a pattern and a section", i+1)
+		}
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		r.re = re
+	}
+	return parsed, nil
+}
+
+// categorizeByRules sets the category of the changes to the section of the first rule whose
+// pattern matches their subject, or Uncategorized. The returned changes are sorted in the order
+// the sections are declared, followed by the Uncategorized ones
+func categorizeByRules(rules []groupRule, changes []Change) []Change {
+	rank := func(section string) int {
+		if i := slices.IndexFunc(rules, func(r groupRule) bool { return r.Section == section }); i >= 0 {
+			return i
+		}
+		return len(rules)
+	}
+	result := slices.Clone(changes)
+	for i := range result {
+		result[i].Category = uncategorized
+		if j := slices.IndexFunc(rules, func(r groupRule) bool { return r.re.MatchString(result[i].Subject) }); j >= 0 {
+			result[i].Category = rules[j].Section
+		}
+	}
+	slices.SortStableFunc(result, func(a, b Change) int {
+		return cmp.Compare(rank(a.Category), rank(b.Category))
+	})
+	return result
+}
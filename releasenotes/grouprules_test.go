@@ -0,0 +1,57 @@
+package releasenotes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCategorizeByRules(t *testing.T) {
+	rules, err := parseGroupRules(`
+- {pattern: "^perf", section: Performance}
+- {pattern: "^fix", section: Bug fixes}
+- {pattern: "^(feat|add)", section: Features}
+- {pattern: "(?i)speed", section: Performance}
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	changes := []Change{
+		{Subject: "feat: add parser"},
+		{Subject: "docs: typo"},
+		{Subject: "fix: crash"},
+		{Subject: "feat: speed up parser"},
+		{Subject: "perf: cache trees"},
+	}
+	want := []Change{
+		{Subject: "perf: cache trees", Category: "Performance"},
+		{Subject: "fix: crash", Category: "Bug fixes"},
+		{Subject: "feat: add parser", Category: "Features"},
+		{Subject: "feat: speed up parser", Category: "Features"},
+		{Subject: "docs: typo", Category: uncategorized},
+	}
+	if got := categorizeByRules(rules, changes); !reflect.DeepEqual(got, want) {
+		t.Errorf("categorizeByRules() = %+v, want %+v", got, want)
+	}
+	if changes[0].Category != "" {
+		t.Error("the provided changes must not be modified")
+	}
+
+	notes := renderChanges(Options{}, want)
+	wantNotes := "### Performance\n* perf: cache trees\n\n### Bug fixes\n* fix: crash\n\n" +
+		"### Features\n* feat: add parser\n* feat: speed up parser\n\n### Uncategorized\n* docs: typo"
+	if notes != wantNotes {
+		t.Errorf("renderChanges() = %q, want %q", notes, wantNotes)
+	}
+}
+
+func TestParseGroupRules_JSON(t *testing.T) {
+	rules, err := parseGroupRules(`[{"pattern": "^perf:", "section": "Performance"}]`)
+	if err != nil || len(rules) != 1 || rules[0].Section != "Performance" || !rules[0].re.MatchString("perf: x") {
+		t.Errorf("parseGroupRules() = %+v, %v", rules, err)
+	}
+	for _, invalid := range []string{`[]`, `{pattern: x}`, `[{pattern: "(", section: S}]`} {
+		if _, err := parseGroupRules(invalid); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package releasenotes
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// redactedHeaders are the request headers that carry credentials, so they are never traced
+var redactedHeaders = []string{"Authorization", "PRIVATE-TOKEN"}
+
+// traceTransport logs every HTTP request with its response status and the remaining API rate
+// limit, to diagnose unexpected API responses
+type traceTransport struct {
+	next http.RoundTripper
+	log  *slog.Logger
+}
+
+func newTraceTransport(next http.RoundTripper) *traceTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &traceTransport{next: next, log: slog.Default()}
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	attrs := []any{
+		"method", req.Method,
+		"url", req.URL.String(),
+		"headers", redactHeaders(req.Header),
+		"duration", time.Since(start),
+	}
+	if err != nil {
+		t.log.Info("HTTP request failed", append(attrs, "error", err)...)
+		return resp, err
+	}
+	// GitHub and GitLab name the rate limit headers differently
+	remaining := resp.Header.Get("X-RateLimit-Remaining")
+	if remaining == "" {
+		remaining = resp.Header.Get("RateLimit-Remaining")
+	}
+	t.log.Info("HTTP request", append(attrs, "status", resp.StatusCode, "rateLimitRemaining", remaining)...)
+	return resp, nil
+}
+
+// redactHeaders returns a copy of the headers whose credentials are replaced by a placeholder
+func redactHeaders(h http.Header) http.Header {
+	redacted := h.Clone()
+	for _, name := range redactedHeaders {
+		if redacted.Get(name) != "" {
+			redacted.Set(name, "REDACTED")
+		}
+	}
+	return redacted
+}
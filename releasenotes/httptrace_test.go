@@ -0,0 +1,45 @@
+package releasenotes
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTraceTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+	}))
+	t.Cleanup(srv.Close)
+	logs := &bytes.Buffer{}
+	tt := newTraceTransport(nil)
+	tt.log = slog.New(slog.NewTextHandler(logs, nil))
+	client := &http.Client{Transport: tt}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/repos/owner/repo/git/ref/tags/v1.0.0", nil)
+	req.Header.Set("Authorization", "Bearer secret-token")
+	req.Header.Set("PRIVATE-TOKEN", "gitlab-secret")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	out := logs.String()
+	for _, want := range []string{"method=GET", "/repos/owner/repo/git/ref/tags/v1.0.0", "status=404", "rateLimitRemaining=4999", "REDACTED"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("trace %q does not contain %q", out, want)
+		}
+	}
+	if strings.Contains(out, "secret") {
+		t.Errorf("trace leaks the credentials: %q", out)
+	}
+	// the request that is sent is not modified
+	if req.Header.Get("Authorization") != "Bearer secret-token" {
+		t.Errorf("the request headers must not be redacted")
+	}
+}
@@ -0,0 +1,118 @@
+package releasenotes
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v57/github"
+)
+
+const uncategorized = "Uncategorized"
+
+// resolvePullRequests sets the number and labels of the pull request that introduced each change
+func (rnw *ReleaseNotesWriter) resolvePullRequests(ctx context.Context, owner, repo string, changes []Change) error {
+	for i := range changes {
+		prs, _, err := rnw.client.PullRequests.ListPullRequestsWithCommit(ctx, owner, repo, changes[i].SHA, nil)
+		if err != nil {
+			return fmt.Errorf("failed to get pull requests for commit %s: %w", changes[i].SHA, err)
+		}
+		pr := pullRequestFor(changes[i], prs)
+		if pr == nil {
+			continue
+		}
+		changes[i].PR = pr.GetNumber()
+		changes[i].PRTitle = pr.GetTitle()
+		changes[i].Labels = nil
+		for _, label := range pr.Labels {
+			changes[i].Labels = append(changes[i].Labels, label.GetName())
+		}
+	}
+	return nil
+}
+
+// collapsePullRequests replaces the changes that belong to the same pull request by a single
+// entry, in the position of the first one, whose subject is the pull request title and number.
+// The changes without a pull request are kept as they are
+func (rnw *ReleaseNotesWriter) collapsePullRequests(changes []Change) []Change {
+	var result []Change
+	seen := map[int]bool{}
+	for _, c := range changes {
+		if c.PR == 0 || c.PRTitle == "" {
+			result = append(result, c)
+			continue
+		}
+		if seen[c.PR] {
+			continue
+		}
+		seen[c.PR] = true
+		c.Subject = fmt.Sprintf("%s (#%d)", c.PRTitle, c.PR)
+		c.Body = ""
+		result = append(result, c)
+	}
+	// the PR suffixes of the new subjects are handled as the ones of the squash-merge commits
+	rnw.handlePRSuffix(result)
+	return result
+}
+
+// pullRequestFor returns the pull request whose number is referenced in the change subject, or
+// the first merged pull request containing the commit
+func pullRequestFor(c Change, prs []*github.PullRequest) *github.PullRequest {
+	for _, pr := range prs {
+		if c.PR != 0 && pr.GetNumber() == c.PR {
+			return pr
+		}
+	}
+	for _, pr := range prs {
+		if pr.MergedAt != nil {
+			return pr
+		}
+	}
+	return nil
+}
+
+// primaryLabel returns the first label from the priority list that the change has. If none of
+// them matches, it returns the first label of the change, or Uncategorized if it has no labels
+func primaryLabel(c Change, priority []string) string {
+	for _, p := range priority {
+		for _, l := range c.Labels {
+			if strings.EqualFold(p, l) {
+				return l
+			}
+		}
+	}
+	if len(c.Labels) > 0 {
+		return c.Labels[0]
+	}
+	return uncategorized
+}
+
+// groupByLabel groups the changes by their primary label. Groups are sorted following the
+// priority list, then alphabetically, with the Uncategorized group at the end
+func groupByLabel(changes []Change, priority []string) (labels []string, groups map[string][]Change) {
+	groups = map[string][]Change{}
+	for _, c := range changes {
+		label := primaryLabel(c, priority)
+		if _, ok := groups[label]; !ok {
+			labels = append(labels, label)
+		}
+		groups[label] = append(groups[label], c)
+	}
+	rank := func(label string) int {
+		if label == uncategorized {
+			return len(priority) + 1
+		}
+		if i := slices.IndexFunc(priority, func(p string) bool { return strings.EqualFold(p, label) }); i >= 0 {
+			return i
+		}
+		return len(priority)
+	}
+	slices.SortStableFunc(labels, func(a, b string) int {
+		if ra, rb := rank(a), rank(b); ra != rb {
+			return ra - rb
+		}
+		return strings.Compare(a, b)
+	})
+	return labels, groups
+}
@@ -0,0 +1,78 @@
+package releasenotes
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestResolvePullRequests(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/commits/{sha}/pulls", func(w http.ResponseWriter, r *http.Request) {
+		switch r.PathValue("sha") {
+		case "c1":
+			fmt.Fprint(w, `[
+				{"number": 7, "merged_at": "2024-06-01T00:00:00Z", "labels": [{"name": "other"}]},
+				{"number": 12, "merged_at": "2024-06-01T00:00:00Z", "labels": [{"name": "bug"}, {"name": "enhancement"}]}
+			]`)
+		case "c2":
+			fmt.Fprint(w, `[{"number": 13, "merged_at": "2024-06-01T00:00:00Z", "labels": [{"name": "enhancement"}]}]`)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	})
+	rnw := newTestWriter(t, Options{}, mux)
+
+	changes := []Change{
+		{SHA: "c1", Subject: "Fix crash (#12)", PR: 12},
+		{SHA: "c2", Subject: "Add feature"},
+		{SHA: "c3", Subject: "Direct push"},
+	}
+	if err := rnw.resolvePullRequests(t.Context(), "owner", "repo", changes); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []Change{
+		{SHA: "c1", Subject: "Fix crash (#12)", PR: 12, Labels: []string{"bug", "enhancement"}},
+		{SHA: "c2", Subject: "Add feature", PR: 13, Labels: []string{"enhancement"}},
+		{SHA: "c3", Subject: "Direct push"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %+v, want %+v", changes, want)
+	}
+}
+
+func TestCollapsePullRequests(t *testing.T) {
+	rnw := &ReleaseNotesWriter{config: Options{PRSuffix: PRSuffixLink}}
+	changes := rnw.collapsePullRequests([]Change{
+		{Repo: "owner/repo", SHA: "c1", Subject: "WIP", PR: 5, PRTitle: "Add feature", Body: "details"},
+		{Repo: "owner/repo", SHA: "c2", Subject: "Direct push"},
+		{Repo: "owner/repo", SHA: "c3", Subject: "Fix tests", PR: 5, PRTitle: "Add feature"},
+		{Repo: "owner/repo", SHA: "c4", Subject: "Fix crash", PR: 6, PRTitle: "Fix crash"},
+	})
+	want := []Change{
+		{Repo: "owner/repo", SHA: "c1", Subject: "Add feature ([#5](https://github.com/owner/repo/pull/5))", PR: 5, PRTitle: "Add feature"},
+		{Repo: "owner/repo", SHA: "c2", Subject: "Direct push"},
+		{Repo: "owner/repo", SHA: "c4", Subject: "Fix crash ([#6](https://github.com/owner/repo/pull/6))", PR: 6, PRTitle: "Fix crash"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %+v, want %+v", changes, want)
+	}
+}
+
+func TestRenderChanges_GroupByLabel(t *testing.T) {
+	changes := []Change{
+		{Subject: "Fix crash", Labels: []string{"enhancement", "bug"}},
+		{Subject: "Direct push"},
+		{Subject: "Add feature", Labels: []string{"enhancement"}},
+		{Subject: "Update docs", Labels: []string{"docs"}},
+	}
+	got := renderChanges(Options{GroupByLabel: true, LabelPriority: []string{"bug", "enhancement"}}, changes)
+	want := "### bug\n* Fix crash\n\n" +
+		"### enhancement\n* Add feature\n\n" +
+		"### docs\n* Update docs\n\n" +
+		"### Uncategorized\n* Direct push"
+	if got != want {
+		t.Errorf("renderChanges() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,110 @@
+package releasenotes
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v57/github"
+)
+
+// named groups of the LockfileSHAPattern
+const (
+	lockfileRepoGroup = "repo"
+	lockfileSHAGroup  = "sha"
+)
+
+// compileLockfilePattern compiles the pattern that extracts the pinned dependencies from the
+// lockfile, which must capture the repository and the commit SHA in the repo and sha named groups
+func compileLockfilePattern(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid lockfile_sha_pattern: %w", err)
+	}
+	if re.SubexpIndex(lockfileRepoGroup) < 0 || re.SubexpIndex(lockfileSHAGroup) < 0 {
+		return nil, fmt.Errorf("lockfile_sha_pattern must capture the (?P<%s>...) and (?P<%s>...) groups",
+			lockfileRepoGroup, lockfileSHAGroup)
+	}
+	return re, nil
+}
+
+// getLockfileSubmodules returns the dependencies pinned in the SubmoduleLockfile at the given
+// commit, as if they were submodules whose path is their repository. The result is memoized for
+// the rest of the run
+func (rnw *ReleaseNotesWriter) getLockfileSubmodules(ctx context.Context, owner, repo, commit string) ([]gitSubmodule, error) {
+	key := commit + ":" + rnw.config.SubmoduleLockfile
+	if submodules, ok := rnw.submodules.get(owner, repo, key); ok {
+		return submodules, nil
+	}
+	submodules, err := rnw.fetchLockfileSubmodules(ctx, owner, repo, commit)
+	if err == nil {
+		rnw.submodules.put(owner, repo, key, submodules)
+	}
+	return submodules, err
+}
+
+func (rnw *ReleaseNotesWriter) fetchLockfileSubmodules(ctx context.Context, owner, repo, commit string) ([]gitSubmodule, error) {
+	pattern, err := compileLockfilePattern(rnw.config.LockfileSHAPattern)
+	if err != nil {
+		return nil, err
+	}
+	path := rnw.config.SubmoduleLockfile
+	file, _, _, err := rnw.client.Repositories.GetContents(ctx, owner, repo, path, &github.RepositoryContentGetOptions{
+		Ref: commit,
+	})
+	if isNotFound(err) {
+		slog.Info("no lockfile found. Assuming no pinned dependencies", "repository", owner+"/"+repo,
+			"commit", commit, "path", path)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s from repository: %w", path, err)
+	}
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s content: %w", path, err)
+	}
+	return parseLockfile(content, pattern), nil
+}
+
+// parseLockfile returns a submodule for each dependency matched by the pattern, pinned to the
+// matched commit. The repository can be an owner/repo name, which is hosted in GitHub, or any
+// URL that is accepted in the .gitmodules files
+func parseLockfile(content string, pattern *regexp.Regexp) []gitSubmodule {
+	var submodules []gitSubmodule
+	for _, m := range pattern.FindAllStringSubmatch(content, -1) {
+		url := m[pattern.SubexpIndex(lockfileRepoGroup)]
+		sm := gitSubmodule{URL: url, Pin: m[pattern.SubexpIndex(lockfileSHAGroup)]}
+		var err error
+		if strings.Contains(url, ":") {
+			sm.Host, sm.Repo, err = parseSubmoduleURL(url)
+		} else {
+			// owner/repo names never contain a colon, unlike the URLs
+			sm.Host, sm.Repo = githubHost, strings.TrimSuffix(url, ".git")
+		}
+		if sm.Repo == "" || sm.Pin == "" {
+			slog.Warn("can't resolve pinned dependency. Ignoring it", "repository", url, "sha", sm.Pin, "error", err)
+			continue
+		}
+		sm.Name, sm.Path = sm.Repo, sm.Repo
+		submodules = append(submodules, sm)
+	}
+	return submodules
+}
+
+// getLockfilePin returns the commit that the dependency at the given path (its repository) is
+// pinned to in the lockfile of the provided commit, or an empty string if it is not pinned there
+func (rnw *ReleaseNotesWriter) getLockfilePin(ctx context.Context, owner, repo, commit, path string) (string, error) {
+	submodules, err := rnw.getLockfileSubmodules(ctx, owner, repo, commit)
+	if err != nil {
+		return "", err
+	}
+	for _, sm := range submodules {
+		if sm.Path == path {
+			return sm.Pin, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,72 @@
+package releasenotes
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+const testLockfilePattern = `(?m)^(?P<repo>\S+)\s+(?P<sha>[0-9a-f]+)$`
+
+func TestParseLockfile(t *testing.T) {
+	pattern, err := compileLockfilePattern(testLockfilePattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	submodules := parseLockfile("org1/lib abc123\nhttps://gitlab.com/group/sub/dep.git def456\nnot-a-dependency\n", pattern)
+	want := []gitSubmodule{
+		{Name: "org1/lib", Path: "org1/lib", URL: "org1/lib", Host: "github.com", Repo: "org1/lib", Pin: "abc123"},
+		{
+			Name: "group/sub/dep", Path: "group/sub/dep", URL: "https://gitlab.com/group/sub/dep.git",
+			Host: "gitlab.com", Repo: "group/sub/dep", Pin: "def456",
+		},
+	}
+	if !reflect.DeepEqual(submodules, want) {
+		t.Errorf("parseLockfile() = %+v, want %+v", submodules, want)
+	}
+}
+
+func TestCompileLockfilePattern_Invalid(t *testing.T) {
+	for _, pattern := range []string{"", "(?P<sha>[0-9a-f]+)", "(?P<repo>"} {
+		if _, err := compileLockfilePattern(pattern); err == nil {
+			t.Errorf("expected error for pattern %q", pattern)
+		}
+	}
+}
+
+func TestGetChangesForSubmodules_Lockfile(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/contents/deps.lock", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("ref") {
+		case "old":
+			gitmodulesHandler("org1/lib 111\norg2/gone 333\n")(w, r)
+		case "new":
+			gitmodulesHandler("org1/lib 222\n")(w, r)
+		}
+	})
+	mux.HandleFunc("GET /repos/owner/repo/contents/.gitmodules", func(http.ResponseWriter, *http.Request) {
+		t.Error("the .gitmodules file must not be read")
+	})
+	mux.HandleFunc("GET /repos/org1/lib/compare/111...222", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"commits": [{"sha": "222", "commit": {"message": "Fix lib"}}]}`)
+	})
+	rnw := newTestWriter(t, Options{SubmoduleLockfile: "deps.lock", LockfileSHAPattern: testLockfilePattern}, mux)
+	rnw.submodules = newSubmodulesCache()
+
+	smChanges, err := rnw.getChangesForSubmodules(t.Context(), "owner", "repo", "new", "old")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(smChanges) != 2 {
+		t.Fatalf("expected an updated and a removed dependency, got %+v", smChanges)
+	}
+	lib, gone := smChanges[0], smChanges[1]
+	if lib.Repo != "org1/lib" || lib.State != SubmoduleUpdated || lib.Old != "111" || lib.New != "222" ||
+		len(lib.Changes) != 1 || lib.Changes[0].Subject != "Fix lib" {
+		t.Errorf("unexpected updated dependency: %+v", lib)
+	}
+	if gone.Repo != "org2/gone" || gone.State != SubmoduleRemoved || gone.Old != "333" {
+		t.Errorf("unexpected removed dependency: %+v", gone)
+	}
+}
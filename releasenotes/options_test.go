@@ -0,0 +1,97 @@
+package releasenotes
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	valid := Options{Token: "token", Repository: "owner/repo", Format: FormatMarkdown, PRSuffix: PRSuffixKeep, SubjectMode: SubjectFirstLine, Layout: LayoutDefault, Mode: ModeGenerate, Since: SinceLastStable, SubmoduleLinks: SubmoduleLinksHost, SubmoduleOrder: SubmoduleOrderGitmodules}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("unexpected error for valid config: %v", err)
+	}
+
+	invalid := valid
+	invalid.Token = ""
+	invalid.Repository = "owner/"
+	invalid.BaseBranch = "main"
+	invalid.PreviousTag = "v1.0.0"
+	invalid.ChangelogMode = true
+	invalid.PRNumber = -1
+	invalid.Since = "yesterday"
+	invalid.SubjectMode = "all"
+	invalid.Layout = "nested"
+	invalid.SubmoduleLinks = "gitlab"
+	invalid.SubmoduleOrder = "alphabetical"
+	invalid.IncludePattern = "^(feat"
+	invalid.GroupRules = `[{"pattern": "^perf:"}]`
+	invalid.ProxyURL = "proxy:3128"
+	invalid.SubmoduleLockfile = "deps.lock"
+	invalid.LockfileSHAPattern = `(?P<sha>[0-9a-f]{40})`
+	err := invalid.Validate()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, problem := range []string{
+		"github_token is required",
+		`invalid repository format: "owner/"`,
+		"base_branch and head_branch must be set together",
+		"previous_tag and base_branch are mutually exclusive",
+		"changelog_mode requires a tag, an output_file",
+		"pr_number can't be negative",
+		"unsupported since: yesterday",
+		"unsupported subject_mode: all",
+		"unsupported layout: nested",
+		"unsupported submodule_links: gitlab",
+		"unsupported submodule_order: alphabetical",
+		"invalid include_pattern",
+		"invalid group_rules: rule 1 must have a pattern and a section",
+		"invalid proxy_url",
+		"lockfile_sha_pattern must capture the (?P<repo>...) and (?P<sha>...) groups",
+	} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("expected %q in error: %v", problem, err)
+		}
+	}
+}
+
+func TestDefaultOptions(t *testing.T) {
+	config := DefaultOptions()
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "github_token is required") ||
+		!strings.Contains(err.Error(), "invalid repository format") {
+		t.Errorf("expected the token and repository to be required, got %v", err)
+	}
+	config.Token, config.Repository, config.Tag = "token", "owner/repo", "v1.0.0"
+	if err := config.Validate(); err != nil {
+		t.Errorf("unexpected error for the default options: %v", err)
+	}
+}
+
+func TestOptionsValidate_SectionOrder(t *testing.T) {
+	config := Options{Token: "token", Repository: "owner/repo", Format: FormatMarkdown, PRSuffix: PRSuffixKeep, SubjectMode: SubjectFirstLine, Layout: LayoutDefault, Mode: ModeGenerate, Since: SinceLastStable, SubmoduleLinks: SubmoduleLinksHost, SubmoduleOrder: SubmoduleOrderGitmodules}
+	for _, order := range [][]string{{"submodule", "main"}, {"main"}} {
+		config.SectionOrder = order
+		if err := config.Validate(); err != nil {
+			t.Errorf("unexpected error for %q: %v", order, err)
+		}
+	}
+	for _, order := range [][]string{{"main", "main"}, {"main", "footer"}} {
+		config.SectionOrder = order
+		if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "invalid section_order") {
+			t.Errorf("expected error for %q, got %v", order, err)
+		}
+	}
+}
+
+func TestOptionsValidate_AppCredentials(t *testing.T) {
+	app := Options{Repository: "owner/repo", Format: FormatMarkdown, PRSuffix: PRSuffixKeep, SubjectMode: SubjectFirstLine, Layout: LayoutDefault, Mode: ModeGenerate, Since: SinceLastStable, SubmoduleLinks: SubmoduleLinksHost, SubmoduleOrder: SubmoduleOrderGitmodules,
+		AppID: 1, AppInstallationID: 2, AppPrivateKey: "key"}
+	if err := app.Validate(); err != nil {
+		t.Errorf("the token must not be required with App credentials: %v", err)
+	}
+
+	app.AppInstallationID = 0
+	if err := app.Validate(); err == nil || !strings.Contains(err.Error(), "must be set together") {
+		t.Errorf("expected incomplete App credentials error, got %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package releasenotes
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// filterByPath returns the changes whose commit modifies any file accepted by match. The files
+// of each commit are provided by commitFiles
+func filterByPath(
+	ctx context.Context, changes []Change,
+	commitFiles func(ctx context.Context, sha string) ([]string, error), match func(file string) bool,
+) ([]Change, error) {
+	var result []Change
+	for _, c := range changes {
+		files, err := commitFiles(ctx, c.SHA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get files of commit %s: %w", c.SHA, err)
+		}
+		for _, file := range files {
+			if match(file) {
+				result = append(result, c)
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// excludeSubmoduleBumps removes the changes whose commit only modifies the gitlinks of the
+// submodules (or the SubmoduleLockfile), as the submodule sections already describe them
+func (rnw *ReleaseNotesWriter) excludeSubmoduleBumps(
+	ctx context.Context, owner, repo, commit, prevCommit string, changes []Change,
+) ([]Change, error) {
+	pointers := map[string]struct{}{}
+	if rnw.config.SubmoduleLockfile != "" {
+		pointers[strings.Trim(rnw.config.SubmoduleLockfile, "/")] = struct{}{}
+	} else {
+		for _, c := range []string{commit, prevCommit} {
+			if c == "" {
+				continue
+			}
+			submodules, err := rnw.getSubmodulePathRepo(ctx, owner, repo, c)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get submodule paths: %w", err)
+			}
+			for _, sm := range submodules {
+				pointers[sm.Path] = struct{}{}
+			}
+		}
+	}
+	if len(pointers) == 0 {
+		return changes, nil
+	}
+	commitFiles := func(ctx context.Context, sha string) ([]string, error) {
+		return rnw.commitFiles(ctx, owner, repo, sha)
+	}
+	// keeps the commits that modify any file besides the submodule pointers
+	return filterByPath(ctx, changes, commitFiles, func(file string) bool {
+		_, ok := pointers[file]
+		return !ok
+	})
+}
+
+// underPath returns a matcher for the files in the given directory or its subdirectories
+func underPath(dir string) func(file string) bool {
+	dir = strings.Trim(dir, "/")
+	return func(file string) bool {
+		return file == dir || strings.HasPrefix(file, dir+"/")
+	}
+}
+
+// matchesGlobs returns a matcher for the files matching any of the glob patterns. Besides the
+// "*" and "?" wildcards, which don't match the "/" separator, "**" matches any number of
+// directories
+func matchesGlobs(patterns []string) func(file string) bool {
+	regexps := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		regexps = append(regexps, globRegexp(strings.TrimPrefix(pattern, "/")))
+	}
+	return func(file string) bool {
+		for _, re := range regexps {
+			if re.MatchString(file) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// globRegexp converts a glob pattern into an anchored regular expression
+func globRegexp(pattern string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			sb.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			sb.WriteString("[^/]*")
+		case pattern[i] == '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
@@ -0,0 +1,118 @@
+package releasenotes
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestUnderPath(t *testing.T) {
+	match := underPath("/pkg/api/")
+	for file, want := range map[string]bool{
+		"pkg/api":          true,
+		"pkg/api/types.go": true,
+		"pkg/api/v1/x.go":  true,
+		"pkg/apis/x.go":    false,
+		"cmd/api/main.go":  false,
+	} {
+		if got := match(file); got != want {
+			t.Errorf("underPath(pkg/api)(%q) = %v, want %v", file, got, want)
+		}
+	}
+}
+
+func TestGetChangesForSubmodules_PathFilter(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/contents/.gitmodules", gitmodulesHandler(`
+[submodule "mono"]
+	path = mono
+	url = https://github.com/org1/mono.git
+`))
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tree": [{"path": "mono", "type": "commit", "sha": "mono-%s"}]}`, r.PathValue("sha"))
+	})
+	mux.HandleFunc("GET /repos/org1/mono/compare/mono-old...mono-new", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"commits": [
+			{"sha": "c1", "commit": {"message": "Change the API"}},
+			{"sha": "c2", "commit": {"message": "Change the UI"}},
+			{"sha": "c3", "commit": {"message": "Move the API"}}
+		]}`)
+	})
+	mux.HandleFunc("GET /repos/org1/mono/commits/{sha}", func(w http.ResponseWriter, r *http.Request) {
+		switch r.PathValue("sha") + "/" + r.URL.Query().Get("page") {
+		case "c1/1":
+			// the matching file is in the second page
+			w.Header().Set("Link", `<`+r.URL.Path+`?page=2>; rel="last"`)
+			fmt.Fprint(w, `{"sha": "c1", "files": [{"filename": "docs/api.md"}]}`)
+		case "c1/2":
+			fmt.Fprint(w, `{"sha": "c1", "files": [{"filename": "api/server.go"}]}`)
+		case "c2/1":
+			fmt.Fprint(w, `{"sha": "c2", "files": [{"filename": "ui/app.js"}]}`)
+		case "c3/1":
+			fmt.Fprint(w, `{"sha": "c3", "files": [{"filename": "server/api.go", "previous_filename": "api/api.go"}]}`)
+		default:
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+	})
+	rnw := newTestWriter(t, Options{SubmodulePathFilter: "api"}, mux)
+
+	smChanges, err := rnw.getChangesForSubmodules(t.Context(), "owner", "repo", "new", "old")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var subjects []string
+	for _, c := range smChanges[0].Changes {
+		subjects = append(subjects, c.Subject)
+	}
+	if want := []string{"Change the API", "Move the API"}; !reflect.DeepEqual(subjects, want) {
+		t.Errorf("subjects = %q, want %q", subjects, want)
+	}
+}
+
+func TestMatchesGlobs(t *testing.T) {
+	match := matchesGlobs([]string{"services/auth/**", "**/*.proto", "/go.mod", "docs/*.md"})
+	for file, want := range map[string]bool{
+		"services/auth/main.go":        true,
+		"services/auth/internal/db.go": true,
+		"services/authz/main.go":       false,
+		"api.proto":                    true,
+		"api/v1/user.proto":            true,
+		"api/v1/user.proto.bak":        false,
+		"go.mod":                       true,
+		"tools/go.mod":                 false,
+		"docs/index.md":                true,
+		"docs/guides/setup.md":         false,
+	} {
+		if got := match(file); got != want {
+			t.Errorf("match(%q) = %v, want %v", file, got, want)
+		}
+	}
+}
+
+func TestExcludeSubmoduleBumps(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/contents/.gitmodules", gitmodulesHandler(`
+[submodule "lib"]
+	path = vendor/lib
+	url = https://github.com/org1/lib.git
+`))
+	files := map[string]string{
+		"bump":  `[{"filename": "vendor/lib"}]`,
+		"mixed": `[{"filename": "vendor/lib"}, {"filename": "go.mod"}]`,
+		"code":  `[{"filename": "main.go"}]`,
+	}
+	mux.HandleFunc("GET /repos/owner/repo/commits/{sha}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"sha": %q, "files": %s}`, r.PathValue("sha"), files[r.PathValue("sha")])
+	})
+	rnw := newTestWriter(t, Options{}, mux)
+
+	changes, err := rnw.excludeSubmoduleBumps(t.Context(), "owner", "repo", "new", "old",
+		[]Change{{SHA: "bump"}, {SHA: "mixed"}, {SHA: "code"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(changes) != 2 || changes[0].SHA != "mixed" || changes[1].SHA != "code" {
+		t.Errorf("expected only the submodule bump to be removed, got %+v", changes)
+	}
+}
@@ -0,0 +1,45 @@
+package releasenotes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/oauth2"
+)
+
+// newTransport returns the base transport of the GitHub and GitLab API clients, which sends the
+// requests through the ProxyURL, if set, or through the proxy of the HTTPS_PROXY, HTTP_PROXY and
+// NO_PROXY environment variables. The credentials of the proxy URL, if any, authenticate the
+// requests to the proxy
+func newTransport(config Options) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if config.ProxyURL != "" {
+		// already checked by Options.Validate
+		proxyURL, _ := url.Parse(config.ProxyURL)
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return transport
+}
+
+// withTransport returns a context whose oauth2 clients send the requests through the transport
+// of newTransport
+func withTransport(ctx context.Context, config Options) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: newTransport(config)})
+}
+
+// validateProxyURL checks that the proxy URL is absolute, with a scheme supported by http.Transport
+func validateProxyURL(proxyURL string) error {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		// the parse errors contain the URL, including its credentials
+		return errors.New("malformed URL")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5" || u.Host == "" {
+		return fmt.Errorf("%q must be an http, https or socks5 URL with a host", u.Redacted())
+	}
+	return nil
+}
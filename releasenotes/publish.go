@@ -0,0 +1,97 @@
+package releasenotes
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/go-github/v57/github"
+)
+
+// releaseBodyLimit is the maximum number of characters of a GitHub release body
+const releaseBodyLimit = 125000
+
+// PublishRelease creates the GitHub release for the Tag of the Options with the generated notes as
+// body, or updates the body of the release if it already exists. It returns the URL of the release
+func PublishRelease(ctx context.Context, client *github.Client, config Options, result Result) (string, error) {
+	owner, repo, _ := strings.Cut(config.Repository, "/")
+	rnw := &ReleaseNotesWriter{config: config, client: newGitHubAPI(client)}
+	return rnw.publishRelease(ctx, owner, repo, result.Notes, result.CompareURL)
+}
+
+// publishRelease creates the GitHub release for the tag with the provided notes as body, or
+// updates the body of the release if it already exists. Notes that exceed the release body limit
+// are truncated, linking to the compareURL, if any. It returns the URL of the release
+func (rnw *ReleaseNotesWriter) publishRelease(ctx context.Context, owner, repo, notes, compareURL string) (string, error) {
+	notes = truncateReleaseBody(notes, compareURL, releaseBodyLimit)
+	existing, err := rnw.findRelease(ctx, owner, repo, rnw.config.Tag)
+	if err != nil {
+		return "", err
+	}
+	release := &github.RepositoryRelease{Body: &notes}
+	if existing == nil {
+		release.TagName = &rnw.config.Tag
+		release.Name = &rnw.config.Tag
+		release.Draft = &rnw.config.Draft
+		release.Prerelease = &rnw.config.Prerelease
+		created, _, err := rnw.client.Repositories.CreateRelease(ctx, owner, repo, release)
+		if err != nil {
+			return "", fmt.Errorf("failed to create release: %w", err)
+		}
+		slog.Info("release created", "tag", rnw.config.Tag, "url", created.GetHTMLURL())
+		return created.GetHTMLURL(), nil
+	}
+	updated, _, err := rnw.client.Repositories.EditRelease(ctx, owner, repo, existing.GetID(), release)
+	if err != nil {
+		return "", fmt.Errorf("failed to update release %d: %w", existing.GetID(), err)
+	}
+	slog.Info("release updated", "tag", rnw.config.Tag, "url", updated.GetHTMLURL())
+	return updated.GetHTMLURL(), nil
+}
+
+// truncateReleaseBody cuts the notes at the last line that fits in limit characters, together
+// with a note linking to the full list of changes in the compareURL
+func truncateReleaseBody(notes, compareURL string, limit int) string {
+	if utf8.RuneCountInString(notes) <= limit {
+		return notes
+	}
+	suffix := "\n\n*The release notes have been truncated"
+	if compareURL != "" {
+		suffix += fmt.Sprintf(". See the [full list of changes](%s)", compareURL)
+	}
+	suffix += "*"
+	slog.Warn("the release notes exceed the GitHub release body limit and have been truncated",
+		"length", utf8.RuneCountInString(notes), "limit", limit)
+	budget := limit - utf8.RuneCountInString(suffix)
+	var sb strings.Builder
+	length := 0
+	for line := range strings.Lines(notes) {
+		length += utf8.RuneCountInString(line)
+		if length > budget {
+			break
+		}
+		sb.WriteString(line)
+	}
+	return strings.TrimRight(sb.String(), "\n") + suffix
+}
+
+// findRelease returns the release for the given tag, or nil if it does not exist. Releases are
+// listed instead of fetched by tag, as the draft releases can't be fetched by their tag
+func (rnw *ReleaseNotesWriter) findRelease(ctx context.Context, owner, repo, tag string) (*github.RepositoryRelease, error) {
+	for page := 1; ; page++ {
+		releases, resp, err := rnw.client.Repositories.ListReleases(ctx, owner, repo, &github.ListOptions{Page: page, PerPage: 100})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list releases: %w", err)
+		}
+		for _, release := range releases {
+			if release.GetTagName() == tag {
+				return release, nil
+			}
+		}
+		if page >= resp.LastPage {
+			return nil, nil
+		}
+	}
+}
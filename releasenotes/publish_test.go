@@ -0,0 +1,87 @@
+package releasenotes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v57/github"
+)
+
+func TestPublishRelease_Create(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/releases", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `[{"id": 1, "tag_name": "v1.0.0"}]`)
+	})
+	mux.HandleFunc("POST /repos/owner/repo/releases", func(w http.ResponseWriter, r *http.Request) {
+		var release github.RepositoryRelease
+		if err := json.NewDecoder(r.Body).Decode(&release); err != nil {
+			t.Fatal(err)
+		}
+		if release.GetTagName() != "v1.1.0" || release.GetName() != "v1.1.0" || release.GetBody() != "notes" ||
+			!release.GetDraft() || release.GetPrerelease() {
+			t.Errorf("unexpected release: %+v", release)
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id": 2, "html_url": "https://github.com/owner/repo/releases/tag/v1.1.0"}`)
+	})
+	rnw := newTestWriter(t, Options{Tag: "v1.1.0", Draft: true}, mux)
+
+	url, err := rnw.publishRelease(t.Context(), "owner", "repo", "notes", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if url != "https://github.com/owner/repo/releases/tag/v1.1.0" {
+		t.Errorf("unexpected release URL: %s", url)
+	}
+}
+
+func TestPublishRelease_UpdateExisting(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/releases", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			// draft releases are found too
+			fmt.Fprint(w, `[{"id": 7, "tag_name": "v1.1.0", "draft": true}]`)
+			return
+		}
+		w.Header().Set("Link", `<`+r.URL.Path+`?page=2>; rel="last"`)
+		fmt.Fprint(w, `[{"id": 1, "tag_name": "v1.0.0"}]`)
+	})
+	mux.HandleFunc("POST /repos/owner/repo/releases", func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("an existing release must not be created again")
+	})
+	mux.HandleFunc("PATCH /repos/owner/repo/releases/7", func(w http.ResponseWriter, r *http.Request) {
+		var release github.RepositoryRelease
+		if err := json.NewDecoder(r.Body).Decode(&release); err != nil {
+			t.Fatal(err)
+		}
+		// the existing release keeps its draft and prerelease state
+		if release.GetBody() != "notes" || release.Draft != nil || release.Prerelease != nil {
+			t.Errorf("unexpected release update: %+v", release)
+		}
+		fmt.Fprint(w, `{"id": 7, "html_url": "https://github.com/owner/repo/releases/tag/v1.1.0"}`)
+	})
+	rnw := newTestWriter(t, Options{Tag: "v1.1.0", Prerelease: true}, mux)
+
+	if _, err := rnw.publishRelease(t.Context(), "owner", "repo", "notes", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestTruncateReleaseBody(t *testing.T) {
+	notes := "## Changes\n" + strings.Repeat("* change\n", 100)
+	if got := truncateReleaseBody(notes, "https://compare", len(notes)); got != notes {
+		t.Errorf("expected the notes under the limit to be kept, got %q", got)
+	}
+
+	suffix := "\n\n*The release notes have been truncated. See the [full list of changes](https://compare)*"
+	got := truncateReleaseBody(notes, "https://compare", len(suffix)+len("## Changes\n* change\n* change\n* ch"))
+	if want := "## Changes\n* change\n* change" + suffix; got != want {
+		t.Errorf("truncateReleaseBody() = %q, want %q", got, want)
+	}
+	if got := truncateReleaseBody(notes, "", 60); got != "## Changes\n\n*The release notes have been truncated*" {
+		t.Errorf("unexpected truncation without compare URL: %q", got)
+	}
+}
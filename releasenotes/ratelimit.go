@@ -0,0 +1,107 @@
+package releasenotes
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// rateLimitRetries is the maximum number of times that a rate-limited request is retried
+	rateLimitRetries = 3
+	// rateLimitMaxWait is the longest wait for a rate limit reset. Requests that should wait
+	// longer fail with the rate limit error
+	rateLimitMaxWait = 2 * time.Minute
+)
+
+// rateLimitTransport retries the GitHub API requests that are rejected by the primary or
+// secondary rate limits, after the time that the API asks to wait. The wait is shared by all the
+// concurrent requests, so they don't hit the limit again until it is reset
+type rateLimitTransport struct {
+	next http.RoundTripper
+	now  func() time.Time
+
+	mu       sync.Mutex
+	resumeAt time.Time
+}
+
+func newRateLimitTransport(next http.RoundTripper) *rateLimitTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &rateLimitTransport{next: next, now: time.Now}
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := t.waitResume(req); err != nil {
+			return nil, err
+		}
+		resp, err := t.next.RoundTrip(req)
+		if err != nil || attempt >= rateLimitRetries {
+			return resp, err
+		}
+		wait, limited := rateLimitWait(resp, t.now())
+		if !limited || wait > rateLimitMaxWait {
+			return resp, nil
+		}
+		// requests with a body can only be retried if it can be read again
+		retry := req.Clone(req.Context())
+		if req.Body != nil {
+			if req.GetBody == nil {
+				return resp, nil
+			}
+			if retry.Body, err = req.GetBody(); err != nil {
+				return resp, nil
+			}
+		}
+		resp.Body.Close()
+		slog.Warn("GitHub API rate limit exceeded. Retrying", "wait", wait, "path", req.URL.Path)
+		t.mu.Lock()
+		if resume := t.now().Add(wait); resume.After(t.resumeAt) {
+			t.resumeAt = resume
+		}
+		t.mu.Unlock()
+		req = retry
+	}
+}
+
+// waitResume blocks until the rate limit is expected to be reset, or the request is cancelled
+func (t *rateLimitTransport) waitResume(req *http.Request) error {
+	t.mu.Lock()
+	wait := t.resumeAt.Sub(t.now())
+	t.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// rateLimitWait returns how long to wait before retrying a rate-limited response, according to
+// its Retry-After header (secondary rate limits) or its X-RateLimit-Reset header (primary rate
+// limits). It returns false if the response wasn't rejected by a rate limit
+func rateLimitWait(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return 0, false
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return max(time.Unix(reset, 0).Sub(now), 0), true
+}
@@ -0,0 +1,88 @@
+package releasenotes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimitTransport_Retries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, `{"message": "You have exceeded a secondary rate limit"}`, http.StatusForbidden)
+			return
+		}
+		body := make([]byte, 4)
+		n, _ := r.Body.Read(body)
+		w.Write(body[:n])
+	}))
+	t.Cleanup(srv.Close)
+	client := &http.Client{Transport: newRateLimitTransport(nil)}
+
+	resp, err := client.Post(srv.URL, "text/plain", strings.NewReader("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls.Load() != 2 {
+		t.Errorf("expected the request to be retried once, got status %d after %d calls", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestRateLimitTransport_GivesUpOnLongWaits(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		http.Error(w, `{"message": "API rate limit exceeded"}`, http.StatusForbidden)
+	}))
+	t.Cleanup(srv.Close)
+	client := &http.Client{Transport: newRateLimitTransport(nil)}
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden || calls.Load() != 1 {
+		t.Errorf("expected the rate limit error without retries, got status %d after %d calls", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestRateLimitWait(t *testing.T) {
+	now := time.Unix(1000, 0)
+	tests := []struct {
+		name    string
+		status  int
+		headers map[string]string
+		wait    time.Duration
+		limited bool
+	}{
+		{name: "ok", status: http.StatusOK, headers: map[string]string{"X-RateLimit-Remaining": "0"}},
+		{name: "forbidden", status: http.StatusForbidden, headers: map[string]string{"X-RateLimit-Remaining": "10"}},
+		{name: "retry after", status: http.StatusTooManyRequests, headers: map[string]string{"Retry-After": "30"}, wait: 30 * time.Second, limited: true},
+		{
+			name: "primary limit", status: http.StatusForbidden, wait: 20 * time.Second, limited: true,
+			headers: map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "1020"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			for k, v := range tt.headers {
+				resp.Header.Set(k, v)
+			}
+			wait, limited := rateLimitWait(resp, now)
+			if wait != tt.wait || limited != tt.limited {
+				t.Errorf("rateLimitWait() = %v, %v, want %v, %v", wait, limited, tt.wait, tt.limited)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package releasenotes
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"strconv"
+)
+
+// matches the own pull request of a change subject: the prefix of the merge commits, like
+// "Merge pull request #123", and the suffix of the squash-merge commits, either bare or already
+// linked by handlePRSuffix, like " (#123)" or " ([#123](https://github.com/owner/repo/pull/123))"
+var (
+	ownPRPrefix = regexp.MustCompile(`^Merge pull request #\d+`)
+	ownPRSuffix = regexp.MustCompile(`\s*\((?:#\d+|\[#\d+\]\([^)]*\))\)\s*$`)
+)
+
+// bareFormat keeps the bare #123 references as they are
+var bareFormat = githubReference("")
+
+// resolveReferences appends the title of the referenced issue or pull request to each bare #123
+// reference in the subject and body of the changes. The own pull request of the subjects is
+// skipped, as its title usually is the subject itself. The references that don't resolve are
+// kept as they are. The bare references of the subjects are also rewritten with the format, as
+// replaceSubmoduleLinks does, before their titles are appended, so the #123 inside the titles
+// are never rewritten
+func (rnw *ReleaseNotesWriter) resolveReferences(
+	ctx context.Context, owner, repo string, changes []Change, format referenceFormat,
+) error {
+	for i := range changes {
+		c := &changes[i]
+		var prefix, suffix string
+		subject := c.Subject
+		if loc := ownPRPrefix.FindStringIndex(subject); loc != nil {
+			prefix, subject = subject[:loc[1]], subject[loc[1]:]
+		}
+		if loc := ownPRSuffix.FindStringIndex(subject); loc != nil {
+			subject, suffix = subject[:loc[0]], subject[loc[0]:]
+		}
+		subject, err := rnw.inlineTitles(ctx, owner, repo, subject, format)
+		if err != nil {
+			return err
+		}
+		c.Subject = rewriteBareReferences(prefix, format) + subject + rewriteBareReferences(suffix, format)
+		if c.Body, err = rnw.inlineTitles(ctx, owner, repo, c.Body, bareFormat); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inlineTitles rewrites each bare #123 reference of the text with the format, appending the title
+// of the referenced issue or pull request. The references are matched as in rewriteBareReferences
+func (rnw *ReleaseNotesWriter) inlineTitles(
+	ctx context.Context, owner, repo, text string, format referenceFormat,
+) (string, error) {
+	var err error
+	text = rewriteBareReferences(text, func(number string) string {
+		reference := format(number)
+		n, convErr := strconv.Atoi(number)
+		if err != nil || convErr != nil {
+			return reference
+		}
+		var title string
+		if title, err = rnw.referenceTitle(ctx, owner, repo, n); title == "" {
+			return reference
+		}
+		return reference + " " + title
+	})
+	return text, err
+}
+
+// referenceTitle returns the title of the issue or pull request with the given number, or an
+// empty title if it can't be resolved. Only the cancellation of the context is returned as an
+// error, since the titles are informative
+func (rnw *ReleaseNotesWriter) referenceTitle(ctx context.Context, owner, repo string, number int) (string, error) {
+	if title, ok := rnw.titles.get(owner, repo, number); ok {
+		return title, nil
+	}
+	// the issues API also returns the pull requests
+	issue, _, err := rnw.client.Issues.Get(ctx, owner, repo, number)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if !isNotFound(err) {
+			slog.Warn("can't resolve reference", "repository", owner+"/"+repo, "number", number, "error", err)
+		}
+	}
+	title := issue.GetTitle()
+	rnw.titles.put(owner, repo, number, title)
+	return title, nil
+}
@@ -0,0 +1,70 @@
+package releasenotes
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestResolveReferences(t *testing.T) {
+	requests := map[string]int{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/issues/{number}", func(w http.ResponseWriter, r *http.Request) {
+		requests[r.PathValue("number")]++
+		switch r.PathValue("number") {
+		case "12":
+			fmt.Fprint(w, `{"number": 12, "title": "Crash on empty tag"}`)
+		case "13":
+			fmt.Fprint(w, `{"number": 13, "title": "Support GitLab", "pull_request": {}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	rnw := newTestWriter(t, Options{}, mux)
+	rnw.titles = newTitlesCache()
+
+	changes := []Change{
+		{Subject: "Handle empty tags, closes #12 (#20)", Body: "Follows #13 and #99"},
+		{Subject: "Merge pull request #21 from owner/branch"},
+		{Subject: "Revert #12 ([#22](https://github.com/owner/repo/pull/22))"},
+		{Subject: "Bump other/repo#12"},
+	}
+	if err := rnw.resolveReferences(t.Context(), "owner", "repo", changes, bareFormat); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []Change{
+		{Subject: "Handle empty tags, closes #12 Crash on empty tag (#20)", Body: "Follows #13 Support GitLab and #99"},
+		{Subject: "Merge pull request #21 from owner/branch"},
+		{Subject: "Revert #12 Crash on empty tag ([#22](https://github.com/owner/repo/pull/22))"},
+		{Subject: "Bump other/repo#12"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("resolveReferences() = %+v, want %+v", changes, want)
+	}
+	// the own pull requests aren't requested, and each reference is only requested once
+	if wantRequests := map[string]int{"12": 1, "13": 1, "99": 1}; !reflect.DeepEqual(requests, wantRequests) {
+		t.Errorf("unexpected requests: %v", requests)
+	}
+}
+
+func TestResolveReferences_SubmoduleFormat(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/org/lib/issues/{number}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("number") != "7" {
+			t.Errorf("unexpected request of #%s", r.PathValue("number"))
+		}
+		fmt.Fprint(w, `{"number": 7, "title": "Crash, follows #5"}`)
+	})
+	rnw := newTestWriter(t, Options{}, mux)
+	rnw.titles = newTitlesCache()
+
+	// #10a isn't a reference, as in replaceSubmoduleLinks
+	changes := []Change{{Subject: "Fix #7 and #10a (#8)"}}
+	if err := rnw.resolveReferences(t.Context(), "org", "lib", changes, githubReference("org/lib")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "Fix org/lib#7 Crash, follows #5 and #10a (org/lib#8)"; changes[0].Subject != want {
+		t.Errorf("subject = %q, want %q", changes[0].Subject, want)
+	}
+}
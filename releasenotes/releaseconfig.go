@@ -0,0 +1,134 @@
+package releasenotes
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v57/github"
+	"gopkg.in/yaml.v3"
+)
+
+// releaseConfigPaths are the locations of the GitHub release notes configuration file
+var releaseConfigPaths = []string{".github/release.yml", ".github/release.yaml"}
+
+// releaseConfig is the GitHub release notes configuration file, as documented in
+// https://docs.github.com/en/repositories/releasing-projects-on-github/automatically-generated-release-notes
+type releaseConfig struct {
+	Changelog struct {
+		Exclude    releaseExclusion  `yaml:"exclude"`
+		Categories []releaseCategory `yaml:"categories"`
+	} `yaml:"changelog"`
+}
+
+type releaseExclusion struct {
+	Labels  []string `yaml:"labels"`
+	Authors []string `yaml:"authors"`
+}
+
+type releaseCategory struct {
+	Title   string           `yaml:"title"`
+	Labels  []string         `yaml:"labels"`
+	Exclude releaseExclusion `yaml:"exclude"`
+}
+
+// fetchReleaseConfig reads the release notes configuration file of the repository at the given
+// commit. It returns nil if the repository does not have it, or if it doesn't declare categories
+func (rnw *ReleaseNotesWriter) fetchReleaseConfig(ctx context.Context, owner, repo, commit string) (*releaseConfig, error) {
+	for _, path := range releaseConfigPaths {
+		file, _, _, err := rnw.client.Repositories.GetContents(ctx, owner, repo, path, &github.RepositoryContentGetOptions{
+			Ref: commit,
+		})
+		if isNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s from repository: %w", path, err)
+		}
+		content, err := file.GetContent()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s content: %w", path, err)
+		}
+		rc := &releaseConfig{}
+		if err := yaml.Unmarshal([]byte(content), rc); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if len(rc.Changelog.Categories) == 0 {
+			return nil, nil
+		}
+		return rc, nil
+	}
+	return nil, nil
+}
+
+// categorize removes the excluded changes and sets the category of the remaining ones to the
+// title of the first category they belong to, or Uncategorized. The returned changes are sorted
+// in the same order as the categories
+func (rc *releaseConfig) categorize(changes []Change) []Change {
+	categories := rc.Changelog.Categories
+	rank := func(category string) int {
+		if i := slices.IndexFunc(categories, func(c releaseCategory) bool { return c.Title == category }); i >= 0 {
+			return i
+		}
+		return len(categories)
+	}
+	var result []Change
+	for _, c := range changes {
+		if rc.Changelog.Exclude.excludes(c) {
+			continue
+		}
+		c.Category = uncategorized
+		for _, category := range categories {
+			if category.includes(c) {
+				c.Category = category.Title
+				break
+			}
+		}
+		result = append(result, c)
+	}
+	slices.SortStableFunc(result, func(a, b Change) int {
+		return cmp.Compare(rank(a.Category), rank(b.Category))
+	})
+	return result
+}
+
+// includes returns whether the change has any of the category labels (or the category matches
+// any label with "*") and is not excluded from the category
+func (rc *releaseCategory) includes(c Change) bool {
+	if rc.Exclude.excludes(c) {
+		return false
+	}
+	return slices.Contains(rc.Labels, "*") || anyEqualFold(rc.Labels, c.Labels)
+}
+
+// excludes returns whether the change has any of the excluded labels or authors
+func (re *releaseExclusion) excludes(c Change) bool {
+	return anyEqualFold(re.Labels, c.Labels) ||
+		(c.Author != "" && anyEqualFold(re.Authors, []string{c.Author}))
+}
+
+// anyEqualFold returns whether any element of a is equal to any element of b, ignoring case
+func anyEqualFold(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if strings.EqualFold(x, y) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// groupByCategory groups the changes by their category, in order of appearance
+func groupByCategory(changes []Change) (categories []string, groups map[string][]Change) {
+	groups = map[string][]Change{}
+	for _, c := range changes {
+		if _, ok := groups[c.Category]; !ok {
+			categories = append(categories, c.Category)
+		}
+		groups[c.Category] = append(groups[c.Category], c)
+	}
+	return categories, groups
+}
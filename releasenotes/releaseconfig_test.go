@@ -0,0 +1,98 @@
+package releasenotes
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestFetchReleaseConfig(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/contents/.github/release.yml", func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+	})
+	// the contents handler is reused to serve the YAML file as base64 content
+	mux.HandleFunc("GET /repos/owner/repo/contents/.github/release.yaml", gitmodulesHandler(`
+changelog:
+  exclude:
+    labels: [ignore-for-release]
+  categories:
+    - title: Features
+      labels: [enhancement]
+`))
+	rnw := newTestWriter(t, Options{}, mux)
+
+	rc, err := rnw.fetchReleaseConfig(t.Context(), "owner", "repo", "abc")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rc == nil || len(rc.Changelog.Categories) != 1 || rc.Changelog.Categories[0].Title != "Features" ||
+		len(rc.Changelog.Exclude.Labels) != 1 {
+		t.Errorf("unexpected release config: %+v", rc)
+	}
+}
+
+func TestFetchReleaseConfig_Missing(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/contents/{path...}", func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+	})
+	rnw := newTestWriter(t, Options{}, mux)
+
+	rc, err := rnw.fetchReleaseConfig(t.Context(), "owner", "repo", "abc")
+	if err != nil || rc != nil {
+		t.Errorf("expected no config and no error, got %+v, %v", rc, err)
+	}
+}
+
+func TestFetchReleaseConfig_NoCategories(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/contents/.github/release.yml", gitmodulesHandler(`
+changelog:
+  exclude:
+    labels: [ignore-for-release]
+`))
+	rnw := newTestWriter(t, Options{}, mux)
+
+	rc, err := rnw.fetchReleaseConfig(t.Context(), "owner", "repo", "abc")
+	if err != nil || rc != nil {
+		t.Errorf("expected no config and no error, got %+v, %v", rc, err)
+	}
+}
+
+func TestRenderChanges_ReleaseConfigCategories(t *testing.T) {
+	rc := &releaseConfig{}
+	rc.Changelog.Exclude = releaseExclusion{Labels: []string{"ignore-for-release"}, Authors: []string{"dependabot"}}
+	rc.Changelog.Categories = []releaseCategory{
+		{Title: "Breaking Changes", Labels: []string{"breaking-change"}},
+		{Title: "Features", Labels: []string{"Enhancement"}, Exclude: releaseExclusion{Labels: []string{"experimental"}}},
+		{Title: "Other Changes", Labels: []string{"*"}},
+	}
+	changes := rc.categorize([]Change{
+		{Subject: "Add feature", Labels: []string{"enhancement"}},
+		{Subject: "Bump deps", Author: "dependabot"},
+		{Subject: "Fix crash", Labels: []string{"bug"}},
+		{Subject: "Remove API", Labels: []string{"breaking-change", "enhancement"}},
+		{Subject: "Try something", Labels: []string{"enhancement", "experimental"}},
+		{Subject: "Internal", Labels: []string{"ignore-for-release"}},
+	})
+
+	want := "### Breaking Changes\n* Remove API\n\n" +
+		"### Features\n* Add feature\n\n" +
+		"### Other Changes\n* Fix crash\n* Try something"
+	// release.yml categories take precedence over the label grouping
+	if got := renderChanges(Options{GroupByLabel: true}, changes); got != want {
+		t.Errorf("renderChanges() = %q, want %q", got, want)
+	}
+}
+
+func TestCategorize_Uncategorized(t *testing.T) {
+	rc := &releaseConfig{}
+	rc.Changelog.Categories = []releaseCategory{{Title: "Features", Labels: []string{"enhancement"}}}
+	changes := rc.categorize([]Change{
+		{Subject: "Fix crash"},
+		{Subject: "Add feature", Labels: []string{"enhancement"}},
+	})
+	if len(changes) != 2 || changes[0].Category != "Features" || changes[1].Category != uncategorized {
+		t.Errorf("unexpected categorized changes: %+v", changes)
+	}
+}
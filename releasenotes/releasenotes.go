@@ -0,0 +1,969 @@
+// Package releasenotes generates the release notes of a GitHub repository, including the
+// changes of its submodules, as the linked-release-notes action does
+package releasenotes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+	"golang.org/x/mod/semver"
+	"golang.org/x/oauth2"
+)
+
+type ReleaseNotesWriter struct {
+	config Options
+	client *githubAPI
+	// submoduleClient, if set, is used for the API requests to the submodule repositories hosted
+	// in GitHub. See forSubmodules
+	submoduleClient *githubAPI
+	gitlab          *gitlabClient
+	cache           *changesCache
+	submodules      *submodulesCache
+	titles          *titlesCache
+	// groupRules are the parsed GroupRules, if any
+	groupRules  []groupRule
+	previousTag string
+	// githubNotes are the release notes generated by GitHub for the main repository, if requested
+	githubNotes string
+	// initialRelease is set when there is no previous release, so the notes list the whole history
+	initialRelease bool
+	// diverged is set when the tag and the previous tag are on divergent histories
+	diverged bool
+}
+
+// Result contains the generated release notes, both rendered and structured by sections
+type Result struct {
+	ReleaseNotes
+	// Notes are the release notes rendered in the Format of the Options
+	Notes string
+	// Commit and PreviousCommit are the compared commits of the main repository. PreviousCommit
+	// is empty when there is nothing to compare with, e.g. in the initial release
+	Commit         string
+	PreviousCommit string
+	// PreviousTag is the provided or auto-detected tag of the previous release, if any
+	PreviousTag string
+}
+
+// NewHTTPClient returns the HTTP client for the GitHub API requests of the Options: it is
+// authenticated with the Token or as a GitHub App, and it waits for the rate limits to be reset
+func NewHTTPClient(ctx context.Context, config Options) (*http.Client, error) {
+	tc, err := newHTTPClient(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	return instrumentClient(config, tc), nil
+}
+
+// GenerateNotes returns the release notes of the repository and its submodules, as described by
+// the Options, which must be valid (see DefaultOptions). The client performs the GitHub API
+// requests (see NewHTTPClient), also for the submodules unless a SubmoduleToken is provided.
+// The notes aren't written anywhere: see PublishRelease, UpdateChangelog and VerifySnapshot
+func GenerateNotes(ctx context.Context, client *github.Client, config Options) (Result, error) {
+	api := newGitHubAPI(client)
+	submoduleClient := api
+	if config.SubmoduleToken != "" {
+		sc := oauth2.NewClient(withTransport(ctx, config), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: config.SubmoduleToken}))
+		submoduleClient = newGitHubAPI(github.NewClient(instrumentClient(config, sc)))
+	}
+	gitlabHTTP := &http.Client{Transport: newTransport(config)}
+	if config.TraceHTTP {
+		gitlabHTTP.Transport = newTraceTransport(gitlabHTTP.Transport)
+	}
+
+	// Parse repository name, already checked by Options.Validate
+	owner, repo, _ := strings.Cut(config.Repository, "/")
+	rnw := ReleaseNotesWriter{
+		config:          config,
+		client:          api,
+		submoduleClient: submoduleClient,
+		gitlab:          newGitLabClient(gitlabHost, config.GitLabToken, gitlabHTTP),
+		cache:           newChangesCache(config.CacheDir, config.CacheTTL),
+		submodules:      newSubmodulesCache(),
+		titles:          newTitlesCache(),
+	}
+	if config.GroupRules != "" {
+		// already checked by Options.Validate
+		rnw.groupRules, _ = parseGroupRules(config.GroupRules)
+	}
+
+	var commit, prevCommit string
+	var changes []Change
+	var err error
+	if config.BaseBranch != "" && config.HeadBranch != "" {
+		// Get release changes for the head branch since it diverged from the base branch
+		commit, prevCommit, changes, err = rnw.changesForBranches(ctx, owner, repo)
+		if err != nil {
+			return Result{}, err
+		}
+	} else {
+		if err := rnw.fetchPreviousTag(ctx, owner, repo); err != nil {
+			return Result{}, fmt.Errorf("fetching previous tag: %w", err)
+		}
+		slog.Info("resolved previous tag", "tag", rnw.previousTag)
+
+		// Get release changes for main repository
+		commit, prevCommit, changes, err = rnw.changesForMain(ctx, owner, repo)
+		if err != nil {
+			return Result{}, err
+		}
+	}
+	slog.Info("comparing commits", "commit", commit, "previous", prevCommit)
+
+	if len(config.PathFilter) > 0 {
+		commitFiles := func(ctx context.Context, sha string) ([]string, error) {
+			return rnw.commitFiles(ctx, owner, repo, sha)
+		}
+		if changes, err = filterByPath(ctx, changes, commitFiles, matchesGlobs(config.PathFilter)); err != nil {
+			return Result{}, err
+		}
+	}
+	if config.ExcludeSubmoduleBumps {
+		if changes, err = rnw.excludeSubmoduleBumps(ctx, owner, repo, commit, prevCommit, changes); err != nil {
+			return Result{}, err
+		}
+	}
+
+	// the group rules replace the categories of the .github/release.yml file
+	var releaseCfg *releaseConfig
+	if config.UseReleaseConfig && config.GroupRules == "" {
+		if releaseCfg, err = rnw.fetchReleaseConfig(ctx, owner, repo, commit); err != nil {
+			return Result{}, err
+		}
+	}
+	if config.GroupByLabel || config.GroupByPR || releaseCfg != nil {
+		if err := rnw.resolvePullRequests(ctx, owner, repo, changes); err != nil {
+			return Result{}, err
+		}
+	}
+	if config.GroupByPR {
+		changes = rnw.collapsePullRequests(changes)
+	}
+	if releaseCfg != nil {
+		changes = releaseCfg.categorize(changes)
+	}
+	if len(rnw.groupRules) > 0 {
+		changes = categorizeByRules(rnw.groupRules, changes)
+	}
+	if config.ResolveReferences {
+		if err := rnw.resolveReferences(ctx, owner, repo, changes, bareFormat); err != nil {
+			return Result{}, err
+		}
+	}
+	var versions []VersionChanges
+	if config.PerVersion && config.BaseBranch == "" && prevCommit != "" {
+		if versions, err = rnw.splitByVersion(ctx, owner, repo, prevCommit, changes); err != nil {
+			return Result{}, err
+		}
+	}
+
+	// get release changes for submodule repositories
+	var smChanges []*SubmoduleChanges
+	if prevCommit != "" && !config.SkipSubmodules {
+		smChanges, err = rnw.getChangesForSubmodules(ctx, owner, repo, commit, prevCommit)
+		if err != nil {
+			// only the failures in the main repository are fatal
+			slog.Warn("can't resolve submodule changes. Omitting them", "error", err)
+		}
+		if config.SubmoduleOrder == SubmoduleOrderChangesDesc {
+			sortSubmodulesByChanges(smChanges)
+		}
+	}
+	// the submodule failures aren't fatal, but the notes must not be silently incomplete
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+
+	var releaseDate string
+	if config.Tag != "" && commit != "" {
+		if releaseDate, err = rnw.releaseDate(ctx, owner, repo, commit); err != nil {
+			slog.Warn("can't resolve the release date", "tag", config.Tag, "error", err)
+		}
+	}
+
+	var diffStat *DiffStat
+	if config.ShowDiffstat && prevCommit != "" {
+		if diffStat, err = rnw.diffStat(ctx, owner, repo, commit, prevCommit); err != nil {
+			slog.Warn("can't resolve the diffstat", "commit", commit, "previous", prevCommit, "error", err)
+		}
+	}
+
+	// the versions hold copies of the changes, so they are trimmed too
+	limitWords(changes, rnw.config.MaxWords)
+	for i := range versions {
+		limitWords(versions[i].Changes, rnw.config.MaxWords)
+	}
+	for _, sm := range flattenSubmodules(smChanges) {
+		limitWords(sm.Changes, rnw.config.MaxWords)
+	}
+
+	// Combine release notes
+	notes := ReleaseNotes{
+		Changes:        changes,
+		Versions:       versions,
+		MainBody:       rnw.githubNotes,
+		Submodules:     smChanges,
+		InitialRelease: rnw.initialRelease,
+		Diverged:       rnw.diverged,
+		ReleaseDate:    releaseDate,
+		GenerationDate: time.Now().UTC().Format(releaseDateLayout),
+		DiffStat:       diffStat,
+	}
+	if prevCommit != "" {
+		notes.CompareURL = fmt.Sprintf("%s/%s/compare/%s...%s", serverURL(), config.Repository, prevCommit, commit)
+	}
+	if notes.empty() {
+		slog.Info(noChangesMessage, "commit", commit, "previous", prevCommit)
+	}
+	result := Result{
+		ReleaseNotes:   notes,
+		Commit:         commit,
+		PreviousCommit: prevCommit,
+		PreviousTag:    rnw.previousTag,
+	}
+	switch config.Format {
+	case FormatMarkdown:
+		result.Notes = renderMarkdown(config, notes)
+	case FormatNDJSON:
+		sb := strings.Builder{}
+		if err := WriteNDJSON(&sb, config, result); err != nil {
+			return Result{}, fmt.Errorf("writing NDJSON notes: %w", err)
+		}
+		result.Notes = sb.String()
+	default:
+		return Result{}, fmt.Errorf("unsupported format: %s (expected %s or %s)", config.Format, FormatMarkdown, FormatNDJSON)
+	}
+	return result, nil
+}
+
+// instrumentClient wraps the transport of the HTTP client to retry the rate-limited requests and,
+// if requested, to trace them
+func instrumentClient(config Options, client *http.Client) *http.Client {
+	if config.TraceHTTP {
+		// every retry of the rate-limited requests is traced too
+		client.Transport = newTraceTransport(client.Transport)
+	}
+	// the rate limit waits are shared by all the concurrent requests
+	client.Transport = newRateLimitTransport(client.Transport)
+	return client
+}
+
+// forSubmodules returns the writer that performs the API requests to the submodule repositories,
+// which uses the submodule client if it is different from the main repository one
+func (rnw *ReleaseNotesWriter) forSubmodules() *ReleaseNotesWriter {
+	if rnw.submoduleClient == nil || rnw.submoduleClient == rnw.client {
+		return rnw
+	}
+	sub := *rnw
+	sub.client = rnw.submoduleClient
+	return &sub
+}
+
+// gets each release notes entry for the main branch
+func (rnw *ReleaseNotesWriter) changesForMain(
+	ctx context.Context, owner string, repo string,
+) (
+	commit, prevCommit string, changes []Change, err error,
+) {
+	commit, err = rnw.commitForTagOrBranch(ctx, owner, repo, rnw.config.Tag)
+	if (rnw.config.Tag == "" || isNotFound(err)) && rnw.previousTag == "" && rnw.config.FallbackLastNCommits > 0 {
+		// neither the previous nor the current tag can be resolved
+		return rnw.changesForLastCommits(ctx, owner, repo)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to get commit for tag: %w", err)
+		return
+	}
+	if rnw.previousTag == "" {
+		// first release of the repository: there is nothing to compare with
+		slog.Info("no previous release found. Listing the whole history as the initial release", "commit", commit)
+		rnw.initialRelease = true
+		changes, err = rnw.getChangesUpTo(ctx, owner, repo, commit)
+		if err != nil {
+			err = fmt.Errorf("failed to get changes: %w", err)
+		}
+		return
+	}
+	prevCommit, err = rnw.commitForTag(ctx, owner, repo, rnw.previousTag)
+	if err != nil {
+		err = fmt.Errorf("failed to get commit for previous tag: %w", err)
+		return
+	}
+	reversed, err := rnw.checkTagOrder()
+	if err != nil {
+		return
+	}
+	if reversed {
+		commit, prevCommit = prevCommit, commit
+	}
+	var status string
+	changes, status, err = rnw.compareChanges(ctx, owner, repo, commit, prevCommit)
+	if err != nil {
+		err = fmt.Errorf("failed to get changes: %w", err)
+		return
+	}
+	rnw.diverged = status == compareDiverged
+	if rnw.config.UseGitHubNotes {
+		rnw.githubNotes, err = rnw.generateReleaseNotes(ctx, owner, repo)
+		if err != nil {
+			err = fmt.Errorf("failed to generate GitHub release notes: %w", err)
+			return
+		}
+	}
+	return
+}
+
+// checkTagOrder returns whether the previous tag is newer than the tag, according to semver, so
+// the comparison must be reversed. This is an error unless AllowReverse is set
+func (rnw *ReleaseNotesWriter) checkTagOrder() (reversed bool, err error) {
+	tag, previous := rnw.config.tagVersion(rnw.config.Tag), rnw.config.tagVersion(rnw.previousTag)
+	if !semver.IsValid(tag) || !semver.IsValid(previous) {
+		return false, nil
+	}
+	if semver.Compare(previous, tag) <= 0 {
+		slog.Debug("tags are in order", "previous", previous, "tag", tag)
+		return false, nil
+	}
+	if !rnw.config.AllowReverse {
+		return false, fmt.Errorf("previous tag %s is newer than tag %s. Set allow_reverse to compare them anyway",
+			rnw.previousTag, rnw.config.Tag)
+	}
+	slog.Warn("previous tag is newer than tag. Comparing them in reverse order",
+		"previous", rnw.previousTag, "tag", rnw.config.Tag)
+	return true, nil
+}
+
+// gets a release notes entry for each of the last FallbackLastNCommits commits in the default branch.
+// The returned previous commit is the parent of the oldest listed commit, or empty if the
+// listed commits go back to the root of the repository
+func (rnw *ReleaseNotesWriter) changesForLastCommits(
+	ctx context.Context, owner string, repo string,
+) (
+	commit, prevCommit string, changes []Change, err error,
+) {
+	repository, _, err := rnw.client.Repositories.Get(ctx, owner, repo)
+	if err != nil {
+		err = fmt.Errorf("failed to get repository: %w", err)
+		return
+	}
+	n := rnw.config.FallbackLastNCommits
+	slog.Info("no tags found. Listing the last commits of the default branch",
+		"branch", repository.GetDefaultBranch(), "commits", n)
+	var commits []*github.RepositoryCommit
+	for page := 1; len(commits) < n; page++ {
+		pageCommits, resp, lcErr := rnw.client.Repositories.ListCommits(ctx, owner, repo, &github.CommitsListOptions{
+			SHA:         repository.GetDefaultBranch(),
+			ListOptions: github.ListOptions{Page: page, PerPage: min(n, 100)},
+		})
+		if lcErr != nil {
+			err = fmt.Errorf("failed to list commits: %w", lcErr)
+			return
+		}
+		commits = append(commits, pageCommits...)
+		if page >= resp.LastPage {
+			break
+		}
+	}
+	if len(commits) > n {
+		commits = commits[:n]
+	}
+	if len(commits) == 0 {
+		return
+	}
+	commit = commits[0].GetSHA()
+	if parents := commits[len(commits)-1].Parents; len(parents) > 0 {
+		prevCommit = parents[0].GetSHA()
+	}
+	changes = commitChanges(owner+"/"+repo, rnw.config.SubjectMode, commits)
+	changes = rnw.filterChanges(changes)
+	rnw.handlePRSuffix(changes)
+	return
+}
+
+// gets each release notes entry for the commits in the head branch since it diverged from the
+// base branch. The returned previous commit is the merge-base of both branches
+func (rnw *ReleaseNotesWriter) changesForBranches(
+	ctx context.Context, owner string, repo string,
+) (
+	commit, prevCommit string, changes []Change, err error,
+) {
+	// CompareCommits performs a three-dot comparison, so it returns the merge-base and
+	// only the commits that are reachable from head since then
+	comparison, _, err := rnw.client.Repositories.CompareCommits(ctx, owner, repo,
+		rnw.config.BaseBranch, rnw.config.HeadBranch, nil)
+	if err != nil {
+		err = fmt.Errorf("failed to compare branches: %w", err)
+		return
+	}
+	prevCommit = comparison.GetMergeBaseCommit().GetSHA()
+	commit, err = rnw.commitForRef(ctx, owner, repo, "heads/"+rnw.config.HeadBranch)
+	if err != nil {
+		err = fmt.Errorf("failed to get commit for head branch: %w", err)
+		return
+	}
+	changes = commitChanges(owner+"/"+repo, rnw.config.SubjectMode, comparison.Commits)
+	changes = rnw.filterChanges(changes)
+	rnw.handlePRSuffix(changes)
+	if rnw.config.ExcludeReleased {
+		changes, err = rnw.excludeReleased(ctx, owner, repo, commit, changes)
+	}
+	return
+}
+
+// excludeReleased removes the changes whose commits are reachable from the previous release tag,
+// as they were already shipped (e.g. when they were merged into the head branch from a base branch
+// that has been released since they diverged)
+func (rnw *ReleaseNotesWriter) excludeReleased(ctx context.Context, owner, repo, commit string, changes []Change) ([]Change, error) {
+	if err := rnw.fetchPreviousTag(ctx, owner, repo); err != nil {
+		return nil, fmt.Errorf("fetching previous tag: %w", err)
+	}
+	if rnw.previousTag == "" {
+		slog.Info("no previous release found. Not excluding released commits")
+		return changes, nil
+	}
+	released, err := rnw.commitForTag(ctx, owner, repo, rnw.previousTag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit for previous tag: %w", err)
+	}
+	unreleased, err := rnw.getChanges(ctx, owner, repo, commit, released)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get changes since previous tag: %w", err)
+	}
+	pending := make(map[string]struct{}, len(unreleased))
+	for _, c := range unreleased {
+		pending[c.SHA] = struct{}{}
+	}
+	var result []Change
+	for _, c := range changes {
+		if _, ok := pending[c.SHA]; ok {
+			result = append(result, c)
+		}
+	}
+	slog.Info("excluded released commits", "tag", rnw.previousTag, "excluded", len(changes)-len(result))
+	return result, nil
+}
+
+// If PreviousTag is not set, find the previous tag by iterating through the releases and getting
+// the semantically previous, non-prerelease tag
+func (rnw *ReleaseNotesWriter) fetchPreviousTag(ctx context.Context, owner, repo string) error {
+	if rnw.config.PreviousTag != "" {
+		rnw.previousTag = rnw.config.PreviousTag
+		return nil
+	}
+	tags, err := rnw.listReleaseTags(ctx, owner, repo)
+	if err != nil {
+		return err
+	}
+	slog.Debug("sorted release tags", "tags", tags)
+	if len(tags) == 0 {
+		return nil
+	}
+	i := len(tags) - 1
+	if rnw.config.Tag != "" {
+		for i >= 0 && semver.Compare(rnw.config.tagVersion(rnw.config.Tag), rnw.config.tagVersion(tags[i])) <= 0 {
+			i--
+		}
+		if i < 0 {
+			i = len(tags) - 1
+		}
+	}
+	// goes back the configured number of releases, stopping at the oldest one
+	rnw.previousTag = tags[max(i-max(rnw.config.TagsBack, 1)+1, 0)]
+	return nil
+}
+
+// listReleaseTags returns the tags of the releases with the TagPrefix, sorted by semver, without
+// the prereleases unless Since is last-release. The releases are listed by creation date, so a
+// backport can be created after a newer version: all the pages are listed, up to MaxReleasePages
+func (rnw *ReleaseNotesWriter) listReleaseTags(ctx context.Context, owner, repo string) ([]string, error) {
+	var tags []string
+	for page := 1; ; page++ {
+		releases, resp, err := rnw.client.Repositories.ListReleases(ctx, owner, repo, &github.ListOptions{Page: page, PerPage: 100})
+		if err != nil {
+			return nil, err
+		}
+		for _, release := range releases {
+			if release.TagName != nil && *release.TagName != "" {
+				tn := *release.TagName
+				// discard the tags of other packages
+				version, ok := strings.CutPrefix(tn, rnw.config.TagPrefix)
+				if !ok {
+					continue
+				}
+				// discard prereleases, unless the previous tag can be any release
+				prerelease := release.GetPrerelease() || semver.Prerelease(version) != ""
+				if !prerelease || rnw.config.Since == SinceLastRelease {
+					tags = append(tags, tn)
+				}
+			}
+		}
+		if page >= resp.LastPage {
+			break
+		}
+		if rnw.config.MaxReleasePages > 0 && page >= rnw.config.MaxReleasePages {
+			slog.Warn("max_release_pages reached. The previous tag might not be accurate",
+				"pages", page, "releases", page*100)
+			break
+		}
+	}
+	// tags that are equal for semver (e.g. v1.0.0 and v1.0.0+build) are sorted lexically, so the
+	// chosen previous tag is always the same
+	slices.SortFunc(tags, func(a, b string) int {
+		if c := semver.Compare(rnw.config.tagVersion(a), rnw.config.tagVersion(b)); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	return tags, nil
+}
+
+// commitFiles returns the paths of the files modified by the commit, including the previous path
+// of the renamed files
+func (rnw *ReleaseNotesWriter) commitFiles(ctx context.Context, owner, repo, sha string) ([]string, error) {
+	var files []string
+	for page := 1; ; page++ {
+		commit, resp, err := rnw.client.Repositories.GetCommit(ctx, owner, repo, sha, &github.ListOptions{Page: page, PerPage: 100})
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range commit.Files {
+			files = append(files, file.GetFilename())
+			if prev := file.GetPreviousFilename(); prev != "" {
+				files = append(files, prev)
+			}
+		}
+		if page >= resp.LastPage {
+			break
+		}
+	}
+	return files, nil
+}
+
+// tagsByCommit returns the tag names of the repository, indexed by the SHA of the commit they
+// point to. If several tags point to the same commit, the first listed one is kept
+func (rnw *ReleaseNotesWriter) tagsByCommit(ctx context.Context, owner, repo string) (map[string]string, error) {
+	tags := map[string]string{}
+	for page := 1; ; page++ {
+		pageTags, resp, err := rnw.client.Repositories.ListTags(ctx, owner, repo, &github.ListOptions{Page: page, PerPage: 100})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tags: %w", err)
+		}
+		for _, tag := range pageTags {
+			if sha := tag.GetCommit().GetSHA(); sha != "" {
+				if _, ok := tags[sha]; !ok {
+					tags[sha] = tag.GetName()
+				}
+			}
+		}
+		if page >= resp.LastPage {
+			break
+		}
+	}
+	return tags, nil
+}
+
+// isNotFound returns whether the error is a 404 response from the GitHub API
+func isNotFound(err error) bool {
+	var ghErr *github.ErrorResponse
+	return errors.As(err, &ghErr) && ghErr.Response != nil && ghErr.Response.StatusCode == http.StatusNotFound
+}
+
+// isUnprocessable returns whether the error is a GitHub API response with the 422 status, which
+// the comparisons return when the commits have no common history
+func isUnprocessable(err error) bool {
+	var ghErr *github.ErrorResponse
+	return errors.As(err, &ghErr) && ghErr.Response != nil && ghErr.Response.StatusCode == http.StatusUnprocessableEntity
+}
+
+// isForbidden returns whether the error is a GitHub API response with the 403 status
+func isForbidden(err error) bool {
+	var ghErr *github.ErrorResponse
+	return errors.As(err, &ghErr) && ghErr.Response != nil && ghErr.Response.StatusCode == http.StatusForbidden
+}
+
+// releaseDateLayout is the format of the release dates
+const releaseDateLayout = "2006-01-02"
+
+// releaseDate returns the date when the GitHub release of the tag was published or, if it isn't
+// published, the date of the tagged commit
+func (rnw *ReleaseNotesWriter) releaseDate(ctx context.Context, owner, repo, commit string) (string, error) {
+	release, _, err := rnw.client.Repositories.GetReleaseByTag(ctx, owner, repo, rnw.config.Tag)
+	if err != nil && !isNotFound(err) {
+		return "", fmt.Errorf("failed to get release: %w", err)
+	}
+	if published := release.GetPublishedAt(); !published.IsZero() {
+		return published.UTC().Format(releaseDateLayout), nil
+	}
+	gitCommit, _, err := rnw.client.Git.GetCommit(ctx, owner, repo, commit)
+	if err != nil {
+		return "", fmt.Errorf("failed to get commit %s: %w", commit, err)
+	}
+	return gitCommit.GetCommitter().GetDate().UTC().Format(releaseDateLayout), nil
+}
+
+func (rnw *ReleaseNotesWriter) commitForTag(ctx context.Context, owner, repo, tag string) (string, error) {
+	return rnw.commitForRef(ctx, owner, repo, "tags/"+tag)
+}
+
+// commitForTagOrBranch returns the commit of the tag or, if there is no such tag, the head of the
+// branch with the same name, so the notes can preview the changes pending to be released
+func (rnw *ReleaseNotesWriter) commitForTagOrBranch(ctx context.Context, owner, repo, name string) (string, error) {
+	commit, err := rnw.commitForTag(ctx, owner, repo, name)
+	if name == "" || !isNotFound(err) {
+		return commit, err
+	}
+	head, headErr := rnw.commitForRef(ctx, owner, repo, "heads/"+name)
+	if headErr != nil {
+		// the missing tag is the relevant error
+		return "", err
+	}
+	slog.Info("tag not found. Using the head of the branch with the same name", "branch", name, "commit", head)
+	return head, nil
+}
+
+func (rnw *ReleaseNotesWriter) commitForRef(ctx context.Context, owner, repo, ref string) (string, error) {
+	gitRef, _, err := rnw.client.Git.GetRef(ctx, owner, repo, ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to get %s reference: %w", ref, err)
+	}
+	return gitRef.Object.GetSHA(), nil
+}
+
+// status of a comparison whose commits are on divergent histories
+const compareDiverged = "diverged"
+
+func (rnw *ReleaseNotesWriter) getChanges(ctx context.Context, owner, repo, commit, prevCommit string) ([]Change, error) {
+	changes, _, err := rnw.compareChanges(ctx, owner, repo, commit, prevCommit)
+	return changes, err
+}
+
+// compareChanges returns the changes from prevCommit to commit, and the status of the comparison:
+// ahead, behind, identical or diverged. The commits of a diverged comparison are those since the
+// common ancestor, so the changes of the previous commit that are missing from the commit aren't listed
+func (rnw *ReleaseNotesWriter) compareChanges(ctx context.Context, owner, repo, commit, prevCommit string) ([]Change, string, error) {
+	if commit == prevCommit {
+		// nothing to compare
+		return nil, "identical", nil
+	}
+	key := compareKey(owner, repo, prevCommit, commit)
+	if rnw.config.UseMergeBase {
+		key += "@merge-base"
+	}
+	// the cached subjects and bodies are derived with the subject mode, so they can't be reused
+	// with another one
+	if rnw.config.SubjectMode != "" && rnw.config.SubjectMode != SubjectFirstLine {
+		key += "@" + rnw.config.SubjectMode
+	}
+	changes, status, ok := rnw.cache.get(key)
+	if !ok {
+		comparison, _, err := rnw.client.Repositories.CompareCommits(ctx, owner, repo, prevCommit, commit, nil)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to compare commits: %w", err)
+		}
+		status = comparison.GetStatus()
+		// when the previous commit is in a different lineage, the comparison may contain
+		// divergent commits, so it is repeated from the common ancestor
+		if mergeBase := comparison.GetMergeBaseCommit().GetSHA(); rnw.config.UseMergeBase &&
+			mergeBase != "" && mergeBase != prevCommit {
+			slog.Debug("comparing from merge-base", "repo", owner+"/"+repo, "previous", prevCommit, "mergeBase", mergeBase)
+			comparison, _, err = rnw.client.Repositories.CompareCommits(ctx, owner, repo, mergeBase, commit, nil)
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to compare commits from merge-base %s: %w", mergeBase, err)
+			}
+		}
+		changes = commitChanges(owner+"/"+repo, rnw.config.SubjectMode, comparison.Commits)
+		rnw.cache.put(key, changes, status)
+	}
+	slog.Debug("commits compared", "repo", owner+"/"+repo, "previous", prevCommit, "commit", commit, "status", status)
+	if status == compareDiverged {
+		slog.Warn("the compared commits are on divergent histories. Listing the changes since their common ancestor",
+			"repo", owner+"/"+repo, "previous", prevCommit, "commit", commit)
+	}
+	changes = rnw.filterChanges(changes)
+	rnw.handlePRSuffix(changes)
+	return changes, status, nil
+}
+
+// getChangesUpTo returns the changes for all the commits that are reachable from the provided commit
+func (rnw *ReleaseNotesWriter) getChangesUpTo(ctx context.Context, owner, repo, commit string) ([]Change, error) {
+	var commits []*github.RepositoryCommit
+	for page := 1; ; page++ {
+		pageCommits, resp, err := rnw.client.Repositories.ListCommits(ctx, owner, repo, &github.CommitsListOptions{
+			SHA:         commit,
+			ListOptions: github.ListOptions{Page: page, PerPage: 100},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list commits: %w", err)
+		}
+		commits = append(commits, pageCommits...)
+		if page >= resp.LastPage {
+			break
+		}
+	}
+	changes := commitChanges(owner+"/"+repo, rnw.config.SubjectMode, commits)
+	changes = rnw.filterChanges(changes)
+	rnw.handlePRSuffix(changes)
+	return changes, nil
+}
+
+// getChangesSince returns the changes for the commits that are reachable from the provided commit
+// and newer than prevCommit, for when both commits can't be compared, e.g. because prevCommit was
+// force-pushed away. The listing stops at prevCommit, if found. If the date of prevCommit can't be
+// read either, only the most recent page of commits is listed
+func (rnw *ReleaseNotesWriter) getChangesSince(ctx context.Context, owner, repo, commit, prevCommit string) ([]Change, error) {
+	opts := &github.CommitsListOptions{SHA: commit, ListOptions: github.ListOptions{PerPage: 100}}
+	prev, _, err := rnw.client.Repositories.GetCommit(ctx, owner, repo, prevCommit, nil)
+	bounded := err == nil
+	if bounded {
+		opts.Since = prev.GetCommit().GetCommitter().GetDate().Time
+	} else {
+		slog.Warn("can't read the previous commit. Listing only the most recent commits",
+			"repository", owner+"/"+repo, "commit", prevCommit, "error", err)
+	}
+	var commits []*github.RepositoryCommit
+	for page := 1; ; page++ {
+		opts.Page = page
+		pageCommits, resp, err := rnw.client.Repositories.ListCommits(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list commits: %w", err)
+		}
+		found := false
+		for _, c := range pageCommits {
+			if found = c.GetSHA() == prevCommit; found {
+				break
+			}
+			commits = append(commits, c)
+		}
+		if found || !bounded || page >= resp.LastPage {
+			break
+		}
+	}
+	changes := commitChanges(owner+"/"+repo, rnw.config.SubjectMode, commits)
+	changes = rnw.filterChanges(changes)
+	rnw.handlePRSuffix(changes)
+	return changes, nil
+}
+
+// Change is a release notes entry, corresponding to a commit
+type Change struct {
+	Repo    string `json:"repo"`
+	SHA     string `json:"sha"`
+	Subject string `json:"subject"`
+	Author  string `json:"author,omitempty"`
+	PR      int    `json:"pr,omitempty"`
+	// PRTitle is the title of the pull request that introduced the change
+	PRTitle string `json:"pr_title,omitempty"`
+	// Labels of the pull request that introduced the change
+	Labels []string `json:"labels,omitempty"`
+	// CoAuthors are the names in the Co-authored-by trailers of the commit
+	CoAuthors []string `json:"co_authors,omitempty"`
+	// Body is the commit message after the subject line, without git trailers
+	Body string `json:"body,omitempty"`
+	// Category is the title of the .github/release.yml category of the change, if any
+	Category string `json:"category,omitempty"`
+	// Verified is set when GitHub verified the signature of the commit
+	Verified bool `json:"verified,omitempty"`
+	// Date is the author date of the commit
+	Date time.Time `json:"date,omitzero"`
+}
+
+// candidates for the auto-detected previous tag
+const (
+	SinceLastStable  = "last-stable"
+	SinceLastRelease = "last-release"
+)
+
+const (
+	PRSuffixKeep  = "keep"
+	PRSuffixLink  = "link"
+	PRSuffixStrip = "strip"
+)
+
+const (
+	SubmoduleLinksHost   = "host"
+	SubmoduleLinksGitHub = "github"
+)
+
+const (
+	SubmoduleOrderGitmodules  = "gitmodules"
+	SubmoduleOrderChangesDesc = "changes-desc"
+)
+
+// matches the (#123) suffix that GitHub adds to the squash-merge subjects
+var prSuffix = regexp.MustCompile(`\s*\(#(\d+)\)\s*$`)
+
+// filterChanges removes the changes of the ExcludeCommitAuthors, as well as those whose subject
+// does not match the IncludePattern or matches the ExcludePattern. If CollapseReverts is set, the
+// revert commits and the commits they revert are removed too
+func (rnw *ReleaseNotesWriter) filterChanges(changes []Change) []Change {
+	if rnw.config.CollapseReverts {
+		changes = collapseReverts(changes)
+	}
+	return filterSubjects(rnw.excludeCommitAuthors(changes), rnw.config.IncludePattern, rnw.config.ExcludePattern)
+}
+
+// filterSubjects keeps the changes whose subject matches the include pattern, if any, and then
+// removes those matching the exclude pattern, if any. The patterns are checked by Options.Validate
+func filterSubjects(changes []Change, include, exclude string) []Change {
+	if include == "" && exclude == "" {
+		return changes
+	}
+	var includeRE, excludeRE *regexp.Regexp
+	if include != "" {
+		includeRE = regexp.MustCompile(include)
+	}
+	if exclude != "" {
+		excludeRE = regexp.MustCompile(exclude)
+	}
+	return slices.DeleteFunc(slices.Clone(changes), func(c Change) bool {
+		return includeRE != nil && !includeRE.MatchString(c.Subject) ||
+			excludeRE != nil && excludeRE.MatchString(c.Subject)
+	})
+}
+
+// excludeCommitAuthors removes the changes whose commit author is one of the ExcludeCommitAuthors
+// logins
+func (rnw *ReleaseNotesWriter) excludeCommitAuthors(changes []Change) []Change {
+	if len(rnw.config.ExcludeCommitAuthors) == 0 {
+		return changes
+	}
+	return slices.DeleteFunc(slices.Clone(changes), func(c Change) bool {
+		return c.Author != "" && anyEqualFold(rnw.config.ExcludeCommitAuthors, []string{c.Author})
+	})
+}
+
+// handlePRSuffix converts the trailing (#123) of the squash-merge subjects into a markdown link
+// to the pull request, or removes it, according to the PRSuffix configuration.
+// The PR number is still available in the change.
+func (rnw *ReleaseNotesWriter) handlePRSuffix(changes []Change) {
+	for i := range changes {
+		c := &changes[i]
+		switch rnw.config.PRSuffix {
+		case PRSuffixStrip:
+			c.Subject = prSuffix.ReplaceAllString(c.Subject, "")
+		case PRSuffixLink:
+			c.Subject = prSuffix.ReplaceAllStringFunc(c.Subject, func(suffix string) string {
+				pr := prSuffix.FindStringSubmatch(suffix)[1]
+				return fmt.Sprintf(" ([#%s](%s/%s/pull/%s))", pr, serverURL(), c.Repo, pr)
+			})
+		}
+	}
+}
+
+// serverURL returns the URL of the GitHub server where the action runs
+func serverURL() string {
+	if url := os.Getenv("GITHUB_SERVER_URL"); url != "" {
+		return url
+	}
+	return "https://github.com"
+}
+
+// matches the PR number in squash-merge subjects like "Add feature (#123)" or in merge
+// commits like "Merge pull request #123 from owner/branch"
+var prNumber = regexp.MustCompile(`(?:\(#(\d+)\)\s*$|^Merge pull request #(\d+))`)
+
+// commitChanges returns a release notes entry for the subject of each commit message, as
+// extracted according to the subjectMode
+func commitChanges(repo, subjectMode string, commits []*github.RepositoryCommit) []Change {
+	var changes []Change
+	for _, commit := range commits {
+		if commit.Commit != nil && commit.Commit.Message != nil {
+			c := Change{
+				Repo:    repo,
+				SHA:     commit.GetSHA(),
+				Subject: commitSubject(*commit.Commit.Message, subjectMode),
+				Author:  commit.GetAuthor().GetLogin(),
+				// the compare and list responses already contain the signature verification
+				Verified: commit.Commit.GetVerification().GetVerified(),
+				Date:     commit.Commit.GetAuthor().GetDate().Time,
+			}
+			c.Body, c.CoAuthors = commitBody(*commit.Commit.Message, subjectMode)
+			if c.Author == "" {
+				c.Author = commit.Commit.GetAuthor().GetName()
+			}
+			if m := prNumber.FindStringSubmatch(c.Subject); m != nil {
+				c.PR, _ = strconv.Atoi(m[1] + m[2])
+			}
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+const (
+	SubjectFirstLine = "firstline"
+	SubjectParagraph = "subject"
+)
+
+// splitMessage splits the commit message into its subject and the rest. The subject is the
+// first line or, in the subjectParagraph mode, the lines up to the first blank line, as git does
+func splitMessage(message, subjectMode string) (subject, rest string) {
+	if subjectMode != SubjectParagraph {
+		subject, rest, _ = strings.Cut(message, "\n")
+		return subject, rest
+	}
+	var lines []string
+	for message != "" {
+		var line string
+		line, message, _ = strings.Cut(message, "\n")
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		lines = append(lines, strings.TrimSpace(line))
+	}
+	return strings.Join(lines, " "), message
+}
+
+// commitSubject returns the subject of the commit message, according to the subjectMode
+func commitSubject(message, subjectMode string) string {
+	subject, _ := splitMessage(message, subjectMode)
+	return subject
+}
+
+// commitBody returns the trimmed commit message after the subject without its git trailers,
+// and the names of the co-authors declared in the trailers
+func commitBody(message, subjectMode string) (body string, coAuthorNames []string) {
+	_, body = splitMessage(message, subjectMode)
+	body, trailers := stripTrailers(body)
+	return body, coAuthors(trailers)
+}
+
+// generateReleaseNotes returns the categorized release notes that GitHub generates for the tag
+// (respecting the .github/release.yml configuration), with their headings demoted one level
+// so they nest under the main repository section
+func (rnw *ReleaseNotesWriter) generateReleaseNotes(ctx context.Context, owner, repo string) (string, error) {
+	// Generate release notes using GitHub API
+	opts := &github.GenerateNotesOptions{TagName: rnw.config.Tag}
+	if rnw.previousTag != "" {
+		opts.PreviousTagName = &rnw.previousTag
+	}
+	notes, _, err := rnw.client.Repositories.GenerateReleaseNotes(ctx, owner, repo, opts)
+	if err != nil {
+		return "", err
+	}
+
+	return demoteHeadings(strings.TrimSpace(notes.Body)), nil
+}
+
+// limitWords trims the subject of each entry to its first maxWords words, appending an ellipsis
+// to the trimmed subjects. A maxWords value <= 0 means no limit.
+func limitWords(entries []Change, maxWords int) {
+	if maxWords <= 0 {
+		return
+	}
+	for i := range entries {
+		words := strings.Fields(entries[i].Subject)
+		if len(words) > maxWords {
+			entries[i].Subject = strings.Join(words[:maxWords], " ") + "…"
+		}
+	}
+}
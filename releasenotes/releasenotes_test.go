@@ -0,0 +1,931 @@
+package releasenotes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+)
+
+// newTestWriter returns a ReleaseNotesWriter whose GitHub client sends all the API requests
+// to the provided handler
+func newTestWriter(t *testing.T, config Options, handler http.Handler) *ReleaseNotesWriter {
+	t.Helper()
+	return &ReleaseNotesWriter{config: config, client: newGitHubAPI(newTestClient(t, handler))}
+}
+
+// newTestClient returns a GitHub client whose requests are served by the handler
+func newTestClient(t *testing.T, handler http.Handler) *github.Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+	return client
+}
+
+func TestLimitWords(t *testing.T) {
+	entries := []Change{
+		{Subject: "Add   support for\tconfigurable commit subject lengths"},
+		{Subject: "Short subject"},
+	}
+	limitWords(entries, 3)
+	want := []Change{
+		{Subject: "Add support for…"},
+		{Subject: "Short subject"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("limitWords() = %+v, want %+v", entries, want)
+	}
+}
+
+func TestChangesForBranches(t *testing.T) {
+	// main:        A---B---C
+	//                   \
+	// release-1.x:       D---E
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/compare/main...release-1.x", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{
+			"status": "diverged",
+			"merge_base_commit": {"sha": "bbbbbbb"},
+			"commits": [
+				{"sha": "ddddddd", "commit": {"message": "Backport fix (#12)\n\nlong description"}},
+				{"sha": "eeeeeee", "commit": {"message": "Prepare 1.1 release"}}
+			]
+		}`)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/git/ref/heads/release-1.x", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"ref": "refs/heads/release-1.x", "object": {"sha": "eeeeeee", "type": "commit"}}`)
+	})
+	rnw := newTestWriter(t, Options{BaseBranch: "main", HeadBranch: "release-1.x"}, mux)
+
+	commit, prevCommit, changes, err := rnw.changesForBranches(t.Context(), "owner", "repo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if commit != "eeeeeee" {
+		t.Errorf("commit = %q, want %q", commit, "eeeeeee")
+	}
+	if prevCommit != "bbbbbbb" {
+		t.Errorf("prevCommit = %q, want merge-base %q", prevCommit, "bbbbbbb")
+	}
+	want := []Change{
+		{Repo: "owner/repo", SHA: "ddddddd", Subject: "Backport fix (#12)", Body: "long description", PR: 12},
+		{Repo: "owner/repo", SHA: "eeeeeee", Subject: "Prepare 1.1 release"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %+v, want %+v", changes, want)
+	}
+}
+
+func TestChangesForBranches_ExcludeReleased(t *testing.T) {
+	// develop:  A---B---R (v1.0.0)
+	//                \    \
+	// feature:        C---M---F
+	// M merges the released commit R, which must not be listed again
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/compare/develop...feature", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"merge_base_commit": {"sha": "bbb"}, "commits": [
+			{"sha": "ccc", "commit": {"message": "Start feature"}},
+			{"sha": "rrr", "commit": {"message": "Released fix"}},
+			{"sha": "mmm", "commit": {"message": "Merge develop"}},
+			{"sha": "fff", "commit": {"message": "Finish feature"}}
+		]}`)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/git/ref/heads/feature", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"object": {"sha": "fff", "type": "commit"}}`)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/releases", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `[{"tag_name": "v1.0.0"}]`)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/git/ref/tags/v1.0.0", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"object": {"sha": "rrr", "type": "commit"}}`)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/compare/rrr...fff", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"commits": [
+			{"sha": "ccc", "commit": {"message": "Start feature"}},
+			{"sha": "mmm", "commit": {"message": "Merge develop"}},
+			{"sha": "fff", "commit": {"message": "Finish feature"}}
+		]}`)
+	})
+	rnw := newTestWriter(t, Options{BaseBranch: "develop", HeadBranch: "feature", ExcludeReleased: true}, mux)
+
+	_, _, changes, err := rnw.changesForBranches(t.Context(), "owner", "repo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var shas []string
+	for _, c := range changes {
+		shas = append(shas, c.SHA)
+	}
+	if want := []string{"ccc", "mmm", "fff"}; !reflect.DeepEqual(shas, want) {
+		t.Errorf("changes = %q, want %q", shas, want)
+	}
+}
+
+func TestGetSubmoduleCommits_TrackedBranch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, r *http.Request) {
+		switch r.PathValue("sha") {
+		case "oldcommit":
+			fmt.Fprint(w, `{"tree": [{"path": "lib", "type": "commit", "sha": "oldsubmodule"}]}`)
+		default:
+			// the new tree does not pin the submodule commit
+			fmt.Fprint(w, `{"truncated": true, "tree": [{"path": "README.md", "type": "blob", "sha": "readme"}]}`)
+		}
+	})
+	mux.HandleFunc("GET /repos/smowner/lib/git/ref/heads/stable", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"ref": "refs/heads/stable", "object": {"sha": "stablehead", "type": "commit"}}`)
+	})
+	rnw := newTestWriter(t, Options{}, mux)
+
+	t.Run("tracked branch head is used when the gitlink is missing", func(t *testing.T) {
+		sc, err := rnw.getSubmoduleCommits(t.Context(), "owner", "repo", "oldcommit", "newcommit",
+			gitSubmodule{Path: "lib", Branch: "stable"}, githubSource{rnw: rnw, owner: "smowner", repo: "lib"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := submoduleCommits{Old: "oldsubmodule", New: "stablehead", State: SubmoduleUpdated}
+		if sc != want {
+			t.Errorf("getSubmoduleCommits() = %+v, want %+v", sc, want)
+		}
+	})
+	t.Run("submodule is removed when it does not track any branch", func(t *testing.T) {
+		sc, err := rnw.getSubmoduleCommits(t.Context(), "owner", "repo", "oldcommit", "newcommit",
+			gitSubmodule{Path: "lib"}, githubSource{rnw: rnw, owner: "smowner", repo: "lib"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := submoduleCommits{Old: "oldsubmodule", State: SubmoduleRemoved}
+		if sc != want {
+			t.Errorf("getSubmoduleCommits() = %+v, want %+v", sc, want)
+		}
+	})
+}
+
+func TestGetSubmoduleCommits_States(t *testing.T) {
+	trees := map[string]string{
+		"both":    `{"tree": [{"path": "lib", "type": "commit", "sha": "libsha"}]}`,
+		"bumped":  `{"tree": [{"path": "lib", "type": "commit", "sha": "libsha2"}]}`,
+		"without": `{"tree": [{"path": "lib", "type": "blob", "sha": "notasubmodule"}]}`,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, trees[r.PathValue("sha")])
+	})
+	rnw := newTestWriter(t, Options{}, mux)
+	src := githubSource{rnw: rnw, owner: "smowner", repo: "lib"}
+
+	tests := []struct {
+		name      string
+		old, new  string
+		want      submoduleCommits
+		wantError bool
+	}{
+		{name: "updated", old: "both", new: "bumped",
+			want: submoduleCommits{Old: "libsha", New: "libsha2", State: SubmoduleUpdated}},
+		{name: "unchanged pointer", old: "both", new: "both",
+			want: submoduleCommits{Old: "libsha", New: "libsha", State: SubmoduleUpdated}},
+		{name: "added", old: "without", new: "both",
+			want: submoduleCommits{New: "libsha", State: SubmoduleAdded}},
+		{name: "removed", old: "both", new: "without",
+			want: submoduleCommits{Old: "libsha", State: SubmoduleRemoved}},
+		{name: "missing in both trees", old: "without", new: "without", wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := rnw.getSubmoduleCommits(t.Context(), "owner", "repo", tt.old, tt.new,
+				gitSubmodule{Path: "lib"}, src)
+			if tt.wantError {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", sc)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if sc != tt.want {
+				t.Errorf("getSubmoduleCommits() = %+v, want %+v", sc, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangesForMain_PropagatesCompareError(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/git/ref/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"ref": "refs/tags/%[1]s", "object": {"sha": "sha-%[1]s", "type": "commit"}}`, r.PathValue("tag"))
+	})
+	mux.HandleFunc("GET /repos/owner/repo/compare/{basehead}", func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, `{"message": "Server Error"}`, http.StatusInternalServerError)
+	})
+	rnw := newTestWriter(t, Options{Tag: "v1.1.0"}, mux)
+	rnw.previousTag = "v1.0.0"
+
+	_, _, changes, err := rnw.changesForMain(t.Context(), "owner", "repo")
+	if err == nil {
+		t.Fatalf("expected error, got changes: %+v", changes)
+	}
+	if !strings.Contains(err.Error(), "failed to get changes") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	var ghErr *github.ErrorResponse
+	if !errors.As(err, &ghErr) || ghErr.Response.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected wrapped GitHub error with status 500, got %v", err)
+	}
+}
+
+func TestChangesForMain_Diverged(t *testing.T) {
+	for _, status := range []string{"ahead", compareDiverged} {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /repos/owner/repo/git/ref/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"object": {"sha": "sha-%s", "type": "commit"}}`, r.PathValue("tag"))
+		})
+		mux.HandleFunc("GET /repos/owner/repo/compare/sha-v1.0.0...sha-v1.1.0", func(w http.ResponseWriter, _ *http.Request) {
+			fmt.Fprintf(w, `{"status": %q, "commits": [{"sha": "c1", "commit": {"message": "Add feature"}}]}`, status)
+		})
+		rnw := newTestWriter(t, Options{Tag: "v1.1.0"}, mux)
+		rnw.previousTag = "v1.0.0"
+
+		if _, _, changes, err := rnw.changesForMain(t.Context(), "owner", "repo"); err != nil || len(changes) != 1 {
+			t.Fatalf("unexpected result: %+v, %v", changes, err)
+		}
+		if rnw.diverged != (status == compareDiverged) {
+			t.Errorf("diverged = %v for status %s", rnw.diverged, status)
+		}
+	}
+}
+
+func TestChangesForMain_PreviousTagNewer(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/git/ref/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"object": {"sha": "sha-%s", "type": "commit"}}`, r.PathValue("tag"))
+	})
+	mux.HandleFunc("GET /repos/owner/repo/compare/sha-v1.0.0...sha-v1.1.0", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"commits": [{"sha": "c1", "commit": {"message": "Add feature"}}]}`)
+	})
+
+	rnw := newTestWriter(t, Options{Tag: "v1.0.0"}, mux)
+	rnw.previousTag = "v1.1.0"
+	if _, _, _, err := rnw.changesForMain(t.Context(), "owner", "repo"); err == nil ||
+		!strings.Contains(err.Error(), "previous tag v1.1.0 is newer than tag v1.0.0") {
+		t.Errorf("expected tag order error, got %v", err)
+	}
+
+	rnw = newTestWriter(t, Options{Tag: "v1.0.0", AllowReverse: true}, mux)
+	rnw.previousTag = "v1.1.0"
+	commit, prevCommit, changes, err := rnw.changesForMain(t.Context(), "owner", "repo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if commit != "sha-v1.1.0" || prevCommit != "sha-v1.0.0" || len(changes) != 1 {
+		t.Errorf("unexpected reversed comparison: %s, %s, %+v", commit, prevCommit, changes)
+	}
+}
+
+func TestChangesForMain_UseMergeBase(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/git/ref/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"object": {"sha": "sha-%s", "type": "commit"}}`, r.PathValue("tag"))
+	})
+	mux.HandleFunc("GET /repos/owner/repo/compare/{basehead}", func(w http.ResponseWriter, r *http.Request) {
+		switch r.PathValue("basehead") {
+		case "sha-v1.0.0...sha-v1.1.0":
+			fmt.Fprint(w, `{"merge_base_commit": {"sha": "base"}, "commits": [
+				{"sha": "c1", "commit": {"message": "Backported fix"}},
+				{"sha": "c2", "commit": {"message": "Add feature"}}
+			]}`)
+		case "base...sha-v1.1.0":
+			fmt.Fprint(w, `{"merge_base_commit": {"sha": "base"}, "commits": [
+				{"sha": "c2", "commit": {"message": "Add feature"}}
+			]}`)
+		default:
+			t.Errorf("unexpected comparison: %s", r.URL)
+		}
+	})
+	rnw := newTestWriter(t, Options{Tag: "v1.1.0", UseMergeBase: true}, mux)
+	rnw.previousTag = "v1.0.0"
+
+	_, _, changes, err := rnw.changesForMain(t.Context(), "owner", "repo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(changes) != 1 || changes[0].SHA != "c2" {
+		t.Errorf("expected only the changes since the merge-base, got %+v", changes)
+	}
+}
+
+func TestGetChanges_ExcludeCommitAuthors(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/compare/{basehead}", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"commits": [
+			{"sha": "c1", "author": {"login": "release-bot"}, "commit": {"message": "Bump version"}},
+			{"sha": "c2", "author": {"login": "dev"}, "commit": {"message": "Add feature"}},
+			{"sha": "c3", "author": {"login": "Renovate"}, "commit": {"message": "Update deps"}}
+		]}`)
+	})
+	rnw := newTestWriter(t, Options{ExcludeCommitAuthors: []string{"release-bot", "renovate"}}, mux)
+
+	changes, err := rnw.getChanges(t.Context(), "owner", "repo", "new", "old")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(changes) != 1 || changes[0].SHA != "c2" {
+		t.Errorf("expected only the changes of dev, got %+v", changes)
+	}
+}
+
+func TestFilterSubjects(t *testing.T) {
+	changes := []Change{
+		{Subject: "feat: add option"},
+		{Subject: "fix: crash"},
+		{Subject: "fix(deps): bump library"},
+		{Subject: "chore: release"},
+	}
+	subjects := func(changes []Change) []string {
+		var s []string
+		for _, c := range changes {
+			s = append(s, c.Subject)
+		}
+		return s
+	}
+	tests := []struct {
+		include, exclude string
+		want             []string
+	}{
+		{want: []string{"feat: add option", "fix: crash", "fix(deps): bump library", "chore: release"}},
+		{include: `^(feat|fix)`, want: []string{"feat: add option", "fix: crash", "fix(deps): bump library"}},
+		{exclude: `^chore`, want: []string{"feat: add option", "fix: crash", "fix(deps): bump library"}},
+		// the include pattern is applied first, then the exclude pattern
+		{include: `^(feat|fix)`, exclude: `\(deps\)`, want: []string{"feat: add option", "fix: crash"}},
+		{include: `^fix`, exclude: `^fix`},
+	}
+	for _, tt := range tests {
+		got := subjects(filterSubjects(changes, tt.include, tt.exclude))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("filterSubjects(%q, %q) = %q, want %q", tt.include, tt.exclude, got, tt.want)
+		}
+	}
+	if got := subjects(changes); !reflect.DeepEqual(got, tests[0].want) {
+		t.Errorf("the filtered changes must not be modified: %q", got)
+	}
+}
+
+func TestChangesForMain_Timeout(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/git/ref/tags/{tag}", func(_ http.ResponseWriter, r *http.Request) {
+		// hangs until the client gives up
+		<-r.Context().Done()
+	})
+	rnw := newTestWriter(t, Options{Tag: "v1.1.0"}, mux)
+	rnw.previousTag = "v1.0.0"
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	_, _, _, err := rnw.changesForMain(ctx, "owner", "repo")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded error, got %v", err)
+	}
+}
+
+func TestChangesForMain_BranchHead(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/git/ref/heads/main", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"ref": "refs/heads/main", "object": {"sha": "mainhead", "type": "commit"}}`)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/git/ref/tags/v1.0.0", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"ref": "refs/tags/v1.0.0", "object": {"sha": "released", "type": "commit"}}`)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/compare/released...mainhead", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"commits": [{"sha": "p1", "commit": {"message": "Pending fix"}}]}`)
+	})
+	rnw := newTestWriter(t, Options{Tag: "main", PreviousTag: "v1.0.0"}, mux)
+	rnw.previousTag = "v1.0.0"
+
+	commit, prevCommit, changes, err := rnw.changesForMain(t.Context(), "owner", "repo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if commit != "mainhead" || prevCommit != "released" {
+		t.Errorf("compared %s...%s, want released...mainhead", prevCommit, commit)
+	}
+	if len(changes) != 1 || changes[0].Subject != "Pending fix" {
+		t.Errorf("unexpected changes: %+v", changes)
+	}
+
+	// neither a tag nor a branch
+	rnw = newTestWriter(t, Options{Tag: "v2.0.0"}, mux)
+	rnw.previousTag = "v1.0.0"
+	if _, _, _, err := rnw.changesForMain(t.Context(), "owner", "repo"); !isNotFound(err) {
+		t.Errorf("expected a not found error for the tag, got %v", err)
+	}
+}
+
+func TestChangesForMain_SameCommit(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/git/ref/tags/{tag}", func(w http.ResponseWriter, _ *http.Request) {
+		// both tags point to the same commit
+		fmt.Fprint(w, `{"object": {"sha": "abc", "type": "commit"}}`)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/compare/{basehead}", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("identical commits must not be compared: %s", r.URL)
+	})
+	rnw := newTestWriter(t, Options{Tag: "v1.0.1"}, mux)
+	rnw.previousTag = "v1.0.0"
+
+	commit, prevCommit, changes, err := rnw.changesForMain(t.Context(), "owner", "repo")
+	if err != nil {
+		t.Fatalf("no changes must not be an error: %v", err)
+	}
+	if commit != "abc" || prevCommit != "abc" || len(changes) != 0 {
+		t.Errorf("unexpected result: %s, %s, %+v", commit, prevCommit, changes)
+	}
+}
+
+func TestChangesForMain_GitHubNotes(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/git/ref/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"ref": "refs/tags/%[1]s", "object": {"sha": "sha-%[1]s", "type": "commit"}}`, r.PathValue("tag"))
+	})
+	mux.HandleFunc("GET /repos/owner/repo/compare/{basehead}", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"commits": [{"sha": "abc", "commit": {"message": "Add feature (#3)"}}]}`)
+	})
+	mux.HandleFunc("POST /repos/owner/repo/releases/generate-notes", func(w http.ResponseWriter, r *http.Request) {
+		var opts github.GenerateNotesOptions
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		if opts.TagName != "v1.1.0" || opts.GetPreviousTagName() != "v1.0.0" {
+			t.Errorf("unexpected generate-notes request: %+v", opts)
+		}
+		fmt.Fprint(w, `{"name": "v1.1.0", "body": "## What's Changed\n### Features\n* Add feature by @dev in #3\n"}`)
+	})
+	rnw := newTestWriter(t, Options{Tag: "v1.1.0", UseGitHubNotes: true}, mux)
+	rnw.previousTag = "v1.0.0"
+
+	_, _, changes, err := rnw.changesForMain(t.Context(), "owner", "repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].PR != 3 {
+		t.Errorf("the commit list must still be retrieved, got %+v", changes)
+	}
+	want := "### What's Changed\n#### Features\n* Add feature by @dev in #3"
+	if rnw.githubNotes != want {
+		t.Errorf("githubNotes = %q, want %q", rnw.githubNotes, want)
+	}
+}
+
+func TestFetchPreviousTag_TagsBack(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/releases", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `[{"tag_name": "v1.3.0"}, {"tag_name": "v1.2.0"}, {"tag_name": "v1.1.0"}, {"tag_name": "v1.0.0"}]`)
+	})
+	tests := []struct {
+		tag      string
+		tagsBack int
+		want     string
+	}{
+		{tag: "v1.3.0", tagsBack: 1, want: "v1.2.0"},
+		{tag: "v1.3.0", tagsBack: 2, want: "v1.1.0"},
+		{tag: "v1.4.0", tagsBack: 3, want: "v1.1.0"},
+		{tag: "", tagsBack: 2, want: "v1.2.0"},
+		// not enough releases: the oldest one is used
+		{tag: "v1.3.0", tagsBack: 10, want: "v1.0.0"},
+	}
+	for _, tt := range tests {
+		rnw := newTestWriter(t, Options{Tag: tt.tag, TagsBack: tt.tagsBack}, mux)
+		if err := rnw.fetchPreviousTag(t.Context(), "owner", "repo"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if rnw.previousTag != tt.want {
+			t.Errorf("previous tag of %q with %d tags back = %q, want %q", tt.tag, tt.tagsBack, rnw.previousTag, tt.want)
+		}
+	}
+}
+
+func TestFetchPreviousTag_Pagination(t *testing.T) {
+	requested := map[string]bool{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/releases", func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		requested[page] = true
+		w.Header().Set("Link", `<`+r.URL.Path+`?page=3>; rel="last"`)
+		// the releases are listed by creation date: the v1.2.5 backport was created after v1.3.0
+		switch page {
+		case "1":
+			fmt.Fprint(w, `[{"tag_name": "v2.1.0"}, {"tag_name": "v1.2.5"}]`)
+		case "2":
+			fmt.Fprint(w, `[{"tag_name": "v1.3.0"}, {"tag_name": "v1.2.4"}]`)
+		default:
+			fmt.Fprint(w, `[{"tag_name": "v1.0.0"}]`)
+		}
+	})
+
+	t.Run("lists all the pages of out of order releases", func(t *testing.T) {
+		clear(requested)
+		rnw := newTestWriter(t, Options{Tag: "v2.0.0"}, mux)
+		if err := rnw.fetchPreviousTag(t.Context(), "owner", "repo"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if rnw.previousTag != "v1.3.0" {
+			t.Errorf("previous tag = %q, want %q", rnw.previousTag, "v1.3.0")
+		}
+		if !requested["3"] {
+			t.Error("expected the last page to be requested")
+		}
+	})
+	t.Run("stops at max release pages", func(t *testing.T) {
+		clear(requested)
+		rnw := newTestWriter(t, Options{Tag: "v2.0.0", MaxReleasePages: 1}, mux)
+		if err := rnw.fetchPreviousTag(t.Context(), "owner", "repo"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if rnw.previousTag != "v1.2.5" {
+			t.Errorf("previous tag = %q, want the newest listed older release %q", rnw.previousTag, "v1.2.5")
+		}
+		if requested["2"] {
+			t.Error("unexpected request beyond max release pages")
+		}
+	})
+}
+
+func TestFetchPreviousTag_TagPrefix(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/releases", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `[
+			{"tag_name": "module-b/v1.2.0"}, {"tag_name": "module-a/v1.1.0"}, {"tag_name": "v1.1.5"},
+			{"tag_name": "module-a/v1.2.0-rc.1"}, {"tag_name": "module-a/v1.0.0"}
+		]`)
+	})
+	rnw := newTestWriter(t, Options{Tag: "module-a/v1.2.0", TagPrefix: "module-a/"}, mux)
+	if err := rnw.fetchPreviousTag(t.Context(), "owner", "repo"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rnw.previousTag != "module-a/v1.1.0" {
+		t.Errorf("previous tag = %q, want %q", rnw.previousTag, "module-a/v1.1.0")
+	}
+	if _, err := rnw.checkTagOrder(); err != nil {
+		t.Errorf("unexpected tag order error: %v", err)
+	}
+}
+
+func TestFetchPreviousTag_Since(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/releases", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `[
+			{"tag_name": "v2.0.0-rc.2", "prerelease": true}, {"tag_name": "v2.0.0-rc.1", "prerelease": true},
+			{"tag_name": "v1.9.0", "prerelease": true}, {"tag_name": "v1.8.0"}
+		]`)
+	})
+	for since, want := range map[string]string{SinceLastStable: "v1.8.0", SinceLastRelease: "v2.0.0-rc.2"} {
+		rnw := newTestWriter(t, Options{Tag: "v2.0.0", Since: since}, mux)
+		if err := rnw.fetchPreviousTag(t.Context(), "owner", "repo"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if rnw.previousTag != want {
+			t.Errorf("previous tag since %s = %q, want %q", since, rnw.previousTag, want)
+		}
+	}
+}
+
+func TestFetchPreviousTag_BuildMetadata(t *testing.T) {
+	// the same tags, listed in different orders, must always resolve the same previous tag
+	for _, releases := range []string{
+		`[{"tag_name": "v1.0.0+build.2"}, {"tag_name": "v1.0.0"}, {"tag_name": "v1.0.0+build.1"}, {"tag_name": "v0.9.0"}]`,
+		`[{"tag_name": "v1.0.0+build.1"}, {"tag_name": "v0.9.0"}, {"tag_name": "v1.0.0"}, {"tag_name": "v1.0.0+build.2"}]`,
+		`[{"tag_name": "v1.0.0"}, {"tag_name": "v1.0.0+build.2"}, {"tag_name": "v0.9.0"}, {"tag_name": "v1.0.0+build.1"}]`,
+	} {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /repos/owner/repo/releases", func(w http.ResponseWriter, _ *http.Request) {
+			fmt.Fprint(w, releases)
+		})
+		rnw := newTestWriter(t, Options{Tag: "v1.1.0"}, mux)
+		if err := rnw.fetchPreviousTag(t.Context(), "owner", "repo"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if rnw.previousTag != "v1.0.0+build.2" {
+			t.Errorf("previous tag for releases %s = %q, want %q", releases, rnw.previousTag, "v1.0.0+build.2")
+		}
+	}
+}
+
+func TestChangesForMain_InitialRelease(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/releases", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `[]`)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/git/ref/tags/v0.1.0", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"object": {"sha": "c2", "type": "commit"}}`)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/commits", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sha") != "c2" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `[
+			{"sha": "c2", "commit": {"message": "Add feature"}},
+			{"sha": "c1", "commit": {"message": "Initial commit"}}
+		]`)
+	})
+	rnw := newTestWriter(t, Options{Tag: "v0.1.0"}, mux)
+	if err := rnw.fetchPreviousTag(t.Context(), "owner", "repo"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	commit, prevCommit, changes, err := rnw.changesForMain(t.Context(), "owner", "repo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if commit != "c2" || prevCommit != "" || !rnw.initialRelease {
+		t.Errorf("commit, prevCommit, initialRelease = %q, %q, %v, want %q, %q, true",
+			commit, prevCommit, rnw.initialRelease, "c2", "")
+	}
+	if len(changes) != 2 || changes[1].Subject != "Initial commit" {
+		t.Errorf("expected the whole history, got %+v", changes)
+	}
+}
+
+func TestChangesForMain_FallbackLastNCommits(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/releases", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `[]`)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/git/ref/tags/{tag...}", func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+	})
+	mux.HandleFunc("GET /repos/owner/repo", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"default_branch": "trunk"}`)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/commits", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sha") != "trunk" || r.URL.Query().Get("per_page") != "2" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `[
+			{"sha": "c3", "commit": {"message": "Third commit"}, "parents": [{"sha": "c2"}]},
+			{"sha": "c2", "commit": {"message": "Second commit"}, "parents": [{"sha": "c1"}]}
+		]`)
+	})
+	rnw := newTestWriter(t, Options{Tag: "v0.1.0", FallbackLastNCommits: 2}, mux)
+	if err := rnw.fetchPreviousTag(t.Context(), "owner", "repo"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	commit, prevCommit, changes, err := rnw.changesForMain(t.Context(), "owner", "repo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if commit != "c3" || prevCommit != "c1" {
+		t.Errorf("commit, prevCommit = %q, %q, want %q, %q", commit, prevCommit, "c3", "c1")
+	}
+	want := []Change{
+		{Repo: "owner/repo", SHA: "c3", Subject: "Third commit"},
+		{Repo: "owner/repo", SHA: "c2", Subject: "Second commit"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %+v, want %+v", changes, want)
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	message := "Add a feature that needs a long\nwrapped subject (#12)\n\nDetails"
+	if subject, rest := splitMessage(message, SubjectFirstLine); subject != "Add a feature that needs a long" ||
+		rest != "wrapped subject (#12)\n\nDetails" {
+		t.Errorf("unexpected firstline split: %q, %q", subject, rest)
+	}
+	if subject, rest := splitMessage(message, SubjectParagraph); subject != "Add a feature that needs a long wrapped subject (#12)" ||
+		rest != "Details" {
+		t.Errorf("unexpected subject split: %q, %q", subject, rest)
+	}
+	if subject, rest := splitMessage("Subject only", SubjectParagraph); subject != "Subject only" || rest != "" {
+		t.Errorf("unexpected subject split: %q, %q", subject, rest)
+	}
+
+	var commits []*github.RepositoryCommit
+	if err := json.Unmarshal([]byte(`[{"sha": "c1", "commit": {"message": "Wrapped\nsubject (#3)\n\nBody"}}]`), &commits); err != nil {
+		t.Fatal(err)
+	}
+	changes := commitChanges("owner/repo", SubjectParagraph, commits)
+	if len(changes) != 1 || changes[0].Subject != "Wrapped subject (#3)" || changes[0].PR != 3 || changes[0].Body != "Body" {
+		t.Errorf("unexpected changes: %+v", changes)
+	}
+}
+
+func TestCommitBody(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{message: "Subject only", want: ""},
+		{message: "Subject\n\n", want: ""},
+		{message: "Subject\n\nFirst paragraph\n\nSecond paragraph\n", want: "First paragraph\n\nSecond paragraph"},
+		{
+			message: "Subject\n\nDetails\n\nSigned-off-by: Dev <dev@example.com>\nCo-authored-by: Other <other@example.com>",
+			want:    "Details",
+		},
+		{message: "Subject\n\nSigned-off-by: Dev <dev@example.com>", want: ""},
+	}
+	for _, tt := range tests {
+		if got, _ := commitBody(tt.message, SubjectFirstLine); got != tt.want {
+			t.Errorf("commitBody(%q) = %q, want %q", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestReleaseDate(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/releases/tags/v1.1.0", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"tag_name": "v1.1.0", "published_at": "2024-06-01T22:30:00Z"}`)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/releases/tags/v1.2.0", func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/git/commits/abc", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"sha": "abc", "committer": {"date": "2024-07-15T10:00:00+02:00"}}`)
+	})
+
+	for tag, want := range map[string]string{"v1.1.0": "2024-06-01", "v1.2.0": "2024-07-15"} {
+		rnw := newTestWriter(t, Options{Tag: tag}, mux)
+		date, err := rnw.releaseDate(t.Context(), "owner", "repo", "abc")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if date != want {
+			t.Errorf("release date of %s = %q, want %q", tag, date, want)
+		}
+	}
+}
+
+func TestCommitChanges_Verified(t *testing.T) {
+	var commits []*github.RepositoryCommit
+	if err := json.Unmarshal([]byte(`[
+		{"sha": "c1", "commit": {"message": "Signed", "verification": {"verified": true, "reason": "valid"}}},
+		{"sha": "c2", "commit": {"message": "Unsigned", "verification": {"verified": false, "reason": "unsigned"}}}
+	]`), &commits); err != nil {
+		t.Fatal(err)
+	}
+	changes := commitChanges("owner/repo", SubjectFirstLine, commits)
+	if len(changes) != 2 || !changes[0].Verified || changes[1].Verified {
+		t.Errorf("unexpected verification: %+v", changes)
+	}
+}
+
+func TestCommitChanges_Date(t *testing.T) {
+	var commits []*github.RepositoryCommit
+	if err := json.Unmarshal([]byte(`[
+		{"sha": "c1", "commit": {"message": "Dated", "author": {"name": "Someone", "date": "2024-06-01T23:30:00-02:00"}}}
+	]`), &commits); err != nil {
+		t.Fatal(err)
+	}
+	changes := commitChanges("owner/repo", SubjectFirstLine, commits)
+	if want := time.Date(2024, 6, 2, 1, 30, 0, 0, time.UTC); len(changes) != 1 || !changes[0].Date.Equal(want) {
+		t.Errorf("unexpected date: %+v", changes)
+	}
+}
+
+func TestHandlePRSuffix(t *testing.T) {
+	t.Setenv("GITHUB_SERVER_URL", "https://github.example.com")
+	newChanges := func() []Change {
+		return []Change{
+			{Repo: "owner/repo", Subject: "Add feature (#123)", PR: 123},
+			{Repo: "owner/repo", Subject: "Fix (#12) in the middle"},
+		}
+	}
+	tests := []struct {
+		mode string
+		want []string
+	}{
+		{mode: PRSuffixKeep, want: []string{"Add feature (#123)", "Fix (#12) in the middle"}},
+		{mode: PRSuffixStrip, want: []string{"Add feature", "Fix (#12) in the middle"}},
+		{mode: PRSuffixLink, want: []string{
+			"Add feature ([#123](https://github.example.com/owner/repo/pull/123))",
+			"Fix (#12) in the middle",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			changes := newChanges()
+			rnw := ReleaseNotesWriter{config: Options{PRSuffix: tt.mode}}
+			rnw.handlePRSuffix(changes)
+			for i := range tt.want {
+				if changes[i].Subject != tt.want[i] {
+					t.Errorf("subject[%d] = %q, want %q", i, changes[i].Subject, tt.want[i])
+				}
+			}
+			if changes[0].PR != 123 {
+				t.Errorf("PR number = %d, want 123", changes[0].PR)
+			}
+		})
+	}
+}
+
+func TestGenerateNotes_SkipSubmodules(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/git/ref/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"object": {"sha": "%s-sha", "type": "commit"}}`, r.PathValue("tag"))
+	})
+	mux.HandleFunc("GET /repos/owner/repo/compare/v1.0.0-sha...v1.1.0-sha", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"status": "ahead", "commits": [{"sha": "a", "commit": {"message": "Update lib"}}]}`)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/contents/.gitmodules", func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("unexpected .gitmodules request")
+		w.WriteHeader(http.StatusNotFound)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+
+	result, err := GenerateNotes(t.Context(), client, Options{
+		Repository: "owner/repo", Tag: "v1.1.0", PreviousTag: "v1.0.0", SkipSubmodules: true,
+		Format: FormatMarkdown, PRSuffix: PRSuffixKeep, SubjectMode: SubjectFirstLine, Layout: LayoutDefault,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "## Changes from owner/repo:\n* Update lib\n"; result.Notes != want {
+		t.Errorf("GenerateNotes() notes = %q, want %q", result.Notes, want)
+	}
+}
+
+func TestGenerateNotes_UnsupportedFormat(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/git/ref/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"object": {"sha": "%s-sha", "type": "commit"}}`, r.PathValue("tag"))
+	})
+	mux.HandleFunc("GET /repos/owner/repo/compare/v1.0.0-sha...v1.1.0-sha", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"status": "ahead", "commits": [{"sha": "a", "commit": {"message": "Update lib"}}]}`)
+	})
+	config := DefaultOptions()
+	config.Repository, config.Tag, config.PreviousTag, config.SkipSubmodules = "owner/repo", "v1.1.0", "v1.0.0", true
+	config.Format = ""
+
+	if _, err := GenerateNotes(t.Context(), newTestClient(t, mux), config); err == nil ||
+		!strings.Contains(err.Error(), "unsupported format") {
+		t.Errorf("expected unsupported format error, got %v", err)
+	}
+}
+
+func TestGenerateNotes_UseReleaseConfig(t *testing.T) {
+	for _, useReleaseConfig := range []bool{false, true} {
+		t.Run(fmt.Sprint(useReleaseConfig), func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /repos/owner/repo/git/ref/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, `{"object": {"sha": "%s-sha", "type": "commit"}}`, r.PathValue("tag"))
+			})
+			mux.HandleFunc("GET /repos/owner/repo/compare/v1.0.0-sha...v1.1.0-sha", func(w http.ResponseWriter, _ *http.Request) {
+				fmt.Fprint(w, `{"status": "ahead", "commits": [
+					{"sha": "a", "commit": {"message": "Add feature"}},
+					{"sha": "b", "commit": {"message": "Tweak CI"}}]}`)
+			})
+			releaseConfig := gitmodulesHandler(`
+changelog:
+  exclude:
+    labels: [ignore-for-release]
+  categories:
+    - title: Features
+      labels: [enhancement]
+`)
+			mux.HandleFunc("GET /repos/owner/repo/contents/.github/release.yml", func(w http.ResponseWriter, r *http.Request) {
+				if !useReleaseConfig {
+					t.Error("unexpected release.yml request")
+				}
+				releaseConfig(w, r)
+			})
+			mux.HandleFunc("GET /repos/owner/repo/commits/{sha}/pulls", func(w http.ResponseWriter, r *http.Request) {
+				if !useReleaseConfig {
+					t.Error("unexpected pull request lookup")
+				}
+				label := map[string]string{"a": "enhancement", "b": "ignore-for-release"}[r.PathValue("sha")]
+				fmt.Fprintf(w, `[{"number": 1, "merged_at": "2024-06-01T00:00:00Z", "labels": [{"name": %q}]}]`, label)
+			})
+			srv := httptest.NewServer(mux)
+			t.Cleanup(srv.Close)
+			client := github.NewClient(nil)
+			client.BaseURL, _ = url.Parse(srv.URL + "/")
+
+			result, err := GenerateNotes(t.Context(), client, Options{
+				Repository: "owner/repo", Tag: "v1.1.0", PreviousTag: "v1.0.0", SkipSubmodules: true,
+				UseReleaseConfig: useReleaseConfig, Format: FormatMarkdown, PRSuffix: PRSuffixKeep, SubjectMode: SubjectFirstLine, Layout: LayoutDefault,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := strings.Contains(result.Notes, "Tweak CI"); got == useReleaseConfig {
+				t.Errorf("excluded change listed = %v in notes %q", got, result.Notes)
+			}
+			if got := strings.Contains(result.Notes, "Features"); got != useReleaseConfig {
+				t.Errorf("Features category rendered = %v in notes %q", got, result.Notes)
+			}
+		})
+	}
+}
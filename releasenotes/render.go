@@ -0,0 +1,611 @@
+package releasenotes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+const (
+	FormatMarkdown = "markdown"
+	FormatNDJSON   = "ndjson"
+)
+
+// noChangesMessage replaces the markdown notes when neither the main repository nor its submodules changed
+const noChangesMessage = "No changes since previous release"
+
+// sections of the release notes, whose order can be configured
+const (
+	SectionMain      = "main"
+	SectionSubmodule = "submodule"
+)
+
+// ReleaseNotes contains the changes of the main repository and its submodules
+type ReleaseNotes struct {
+	Changes []Change
+	// Versions, if not empty, split the Changes by the version that introduced them, in
+	// ascending order, so they are rendered under a heading for each version
+	Versions []VersionChanges
+	// MainBody, if not empty, replaces the list of Changes in the main repository section of the
+	// markdown notes
+	MainBody   string
+	Submodules []*SubmoduleChanges
+	// InitialRelease is set when there is no previous release, so the Changes are the whole
+	// history of the main repository
+	InitialRelease bool
+	// Diverged is set when the main repository commits are on divergent histories, so the Changes
+	// are those since their common ancestor
+	Diverged bool
+	// ReleaseDate is the publication date of the release, or the date of the tagged commit
+	ReleaseDate string
+	// GenerationDate is the date when the notes were generated
+	GenerationDate string
+	// CompareURL is the URL of the web view comparing the main repository commits, if any
+	CompareURL string
+	// DiffStat is the size of the main repository changes, if ShowDiffstat is set
+	DiffStat *DiffStat
+}
+
+// empty returns whether there is nothing to report, neither in the main repository nor in the
+// submodules
+func (rn *ReleaseNotes) empty() bool {
+	return rn.mainEmpty() && !slices.ContainsFunc(rn.Submodules, func(sc *SubmoduleChanges) bool { return !sc.empty() })
+}
+
+// mainEmpty returns whether there is nothing to report for the main repository
+func (rn *ReleaseNotes) mainEmpty() bool {
+	return len(rn.Changes) == 0 && rn.MainBody == ""
+}
+
+// renderMarkdown returns the release notes document for the main repository and submodule changes
+func renderMarkdown(config Options, rn ReleaseNotes) string {
+	var notes string
+	if rn.empty() {
+		notes = noChangesMessage + "\n"
+	} else {
+		order := config.SectionOrder
+		if len(order) == 0 {
+			order = []string{SectionMain, SectionSubmodule}
+		}
+		for _, section := range order {
+			switch section {
+			case SectionMain:
+				if rn.mainEmpty() {
+					continue
+				}
+				mainBody := rn.MainBody
+				if mainBody == "" && len(rn.Versions) > 0 {
+					mainBody = renderVersions(config, rn.Versions)
+				} else if mainBody == "" {
+					mainBody = renderChanges(config, rn.Changes)
+				}
+				if config.ShowSummary && len(rn.Changes) > 0 {
+					mainBody = countsSummary(rn.Changes) + "\n\n" + mainBody
+				}
+				if rn.Diverged {
+					mainBody = divergedNote + "\n\n" + mainBody
+				}
+				if rn.DiffStat != nil {
+					mainBody += "\n\n> " + rn.DiffStat.String()
+				}
+				heading := fmt.Sprintf("%s %s:", config.sectionTitlePrefix(),
+					linkIf(config.LinkSections, config.Repository, rn.CompareURL))
+				if rn.InitialRelease {
+					heading = "Initial release"
+				}
+				notes += fmt.Sprintf("\n## %s\n%s\n", heading, mainBody)
+			case SectionSubmodule:
+				for _, sm := range rn.Submodules {
+					notes += sm.render(config)
+				}
+			}
+		}
+		notes = strings.TrimPrefix(notes, "\n")
+		if config.BreakingChanges {
+			if breaking := renderBreakingChanges(config, rn); breaking != "" {
+				notes = breaking + "\n" + notes
+			}
+		}
+	}
+	if config.Header != "" {
+		notes = expandTemplate(config.Header, config, rn) + "\n\n" + notes
+	}
+	if config.Footer != "" {
+		notes += "\n" + expandTemplate(config.Footer, config, rn) + "\n"
+	}
+	return notes
+}
+
+// expandTemplate replaces the {{tag}}, {{release_date}} and {{date}} (the generation date)
+// placeholders of the header or footer text, as well as the environment variables allowed by
+// expandEnv
+func expandTemplate(text string, config Options, rn ReleaseNotes) string {
+	return strings.NewReplacer(
+		"{{tag}}", config.Tag,
+		"{{release_date}}", rn.ReleaseDate,
+		"{{date}}", rn.GenerationDate,
+	).Replace(expandEnv(text))
+}
+
+// demoteHeadings adds a level to the markdown headings of the text, so it can be nested under
+// another heading
+func demoteHeadings(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "#") {
+			lines[i] = "#" + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// expandEnv replaces the $VAR and ${VAR} references to the GitHub Actions default environment
+// variables (GITHUB_* and RUNNER_*) by their values. Any other reference is kept as is, so
+// secrets like GITHUB_TOKEN or the action inputs can't be leaked into the release notes.
+func expandEnv(s string) string {
+	var sb strings.Builder
+	for {
+		i := strings.IndexByte(s, '$')
+		if i < 0 {
+			sb.WriteString(s)
+			return sb.String()
+		}
+		sb.WriteString(s[:i])
+		s = s[i:]
+		// ref is the whole $VAR or ${VAR} reference, and name the variable in it
+		var ref, name string
+		if strings.HasPrefix(s, "${") {
+			if end := strings.IndexByte(s, '}'); end >= 0 {
+				ref, name = s[:end+1], s[2:end]
+			}
+		} else {
+			end := 1
+			for end < len(s) && isEnvNameByte(s[end], end == 1) {
+				end++
+			}
+			ref, name = s[:end], s[1:end]
+		}
+		if ref == "" || !expandableEnv(name) {
+			// not a reference to an allowed variable, so the $ is kept as literal text
+			sb.WriteByte('$')
+			s = s[1:]
+			continue
+		}
+		sb.WriteString(os.Getenv(name))
+		s = s[len(ref):]
+	}
+}
+
+// expandableEnv returns whether the variable can be expanded in the header and footer
+func expandableEnv(name string) bool {
+	return (strings.HasPrefix(name, "GITHUB_") || strings.HasPrefix(name, "RUNNER_")) && name != "GITHUB_TOKEN"
+}
+
+// isEnvNameByte returns whether b can be part of a bare $VAR reference: letters, digits (but not
+// as the first character) and underscores
+func isEnvNameByte(b byte, first bool) bool {
+	return b == '_' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || !first && '0' <= b && b <= '9'
+}
+
+// empty returns whether there is nothing to report for the submodule or its nested submodules
+func (sc *SubmoduleChanges) empty() bool {
+	return sc.State == SubmoduleUpdated && len(sc.Changes) == 0 && sc.Err == nil &&
+		!slices.ContainsFunc(sc.Submodules, func(nested *SubmoduleChanges) bool { return !nested.empty() })
+}
+
+// render returns the markdown section for the submodule changes, or an empty string if there is
+// nothing to report
+func (sc *SubmoduleChanges) render(config Options) string {
+	if sc.empty() {
+		return ""
+	}
+	summary := ""
+	if sc.PreviousRepo != "" {
+		summary = sc.movedNote() + "\n\n"
+	}
+	if sc.Uncompared {
+		summary += fmt.Sprintf("> ⚠️ could not compare submodule commits %s and %s; listing the commits reachable from %s\n\n",
+			sc.oldRef(), sc.newRef(), sc.newRef())
+	}
+	if config.ShowSummary && len(sc.Changes) > 0 {
+		summary += countsSummary(sc.Changes) + "\n\n"
+	}
+	if config.SubmodulePointerSummary && sc.New != "" {
+		summary += sc.pointerSummary() + "\n\n"
+	}
+	if config.SubmoduleBumpSummary && sc.State == SubmoduleUpdated && sc.Old != "" && sc.New != "" {
+		summary += sc.bumpSummary() + "\n\n"
+	}
+	var body string
+	switch sc.State {
+	case SubmoduleRemoved:
+		body = fmt.Sprintf("Submodule %s removed\n", sc.Path)
+	default:
+		body = fmt.Sprintf("%s%s\n", summary, renderChanges(config, sc.Changes))
+		if sc.DiffStat != nil {
+			body += "\n> " + sc.DiffStat.String() + "\n"
+		}
+	}
+	if sc.Err != nil {
+		body += fmt.Sprintf("> ⚠️ could not resolve changes for %s: %v\n", sc.Repo, sc.Err)
+	}
+	for _, nested := range sc.Submodules {
+		body += nested.render(config)
+	}
+	if config.CollapseSubmodules {
+		// the blank lines around the body are required to render its markdown inside the HTML block
+		return fmt.Sprintf("\n<details>\n<summary>%s</summary>\n\n%s\n</details>\n", sc.collapsedSummary(), body)
+	}
+	// nested submodules are rendered with deeper headings
+	heading := strings.Repeat("#", 2+sc.Depth) + " " + sc.heading(config)
+	return "\n" + heading + "\n" + body
+}
+
+// collapsedSummary returns the summary of the collapsed submodule section: its repository and
+// number of changes, e.g. "owner/lib (3 changes)"
+func (sc *SubmoduleChanges) collapsedSummary() string {
+	if sc.State == SubmoduleRemoved {
+		return sc.Repo + " (removed)"
+	}
+	return fmt.Sprintf("%s (%s)", sc.Repo, plural(len(sc.Changes), "change", "changes"))
+}
+
+// heading returns the text of the submodule section heading. If the SubmoduleHeadingTemplate is
+// empty, it returns the default "Changes from owner/repo:" heading, with the SectionTitlePrefix
+// wording, whose repository links to the compare view if LinkSections is set. Otherwise, the
+// {{name}}, {{repo}}, {{path}}, {{old}}, {{new}} and {{compare_url}} placeholders of the template
+// are replaced by the submodule name, repository, path, the tags (or short SHAs, if untagged) of
+// the previous and current commits, and the URL of the compare view
+func (sc *SubmoduleChanges) heading(config Options) string {
+	template := config.SubmoduleHeadingTemplate
+	if template == "" {
+		repo := linkIf(config.LinkSections, sc.Repo, sc.compareURL())
+		if sc.State == SubmoduleAdded {
+			return fmt.Sprintf("%s %s (new submodule %s):", config.sectionTitlePrefix(), repo, sc.Path)
+		}
+		return fmt.Sprintf("%s %s:", config.sectionTitlePrefix(), repo)
+	}
+	return strings.NewReplacer(
+		"{{name}}", sc.Name,
+		"{{repo}}", sc.Repo,
+		"{{path}}", sc.Path,
+		"{{old}}", sc.oldRef(),
+		"{{new}}", sc.newRef(),
+		"{{compare_url}}", sc.compareURL(),
+	).Replace(template)
+}
+
+// sectionTitlePrefix returns the wording that precedes the repository in the default section
+// headings
+func (c *Options) sectionTitlePrefix() string {
+	if c.SectionTitlePrefix == "" {
+		return "Changes from"
+	}
+	return c.SectionTitlePrefix
+}
+
+// linkIf returns the text as a markdown link to the URL, if link is set and the URL is not empty
+func linkIf(link bool, text, url string) string {
+	if !link || url == "" {
+		return text
+	}
+	return "[" + text + "](" + url + ")"
+}
+
+// pointerSummary explains the change of the commit that the submodule points to
+func (sc *SubmoduleChanges) pointerSummary() string {
+	if sc.State == SubmoduleAdded {
+		return fmt.Sprintf("Submodule %s added at %s (%d commits)", sc.Path, sc.newRef(), len(sc.Changes))
+	}
+	return fmt.Sprintf("Submodule %s updated from %s to %s (%d commits)",
+		sc.Path, sc.oldRef(), sc.newRef(), len(sc.Changes))
+}
+
+// movedNote explains that the submodule repository has changed since the previous commit
+func (sc *SubmoduleChanges) movedNote() string {
+	previous := sc.PreviousRepo
+	if sc.PreviousHost != sc.Host {
+		previous = sc.PreviousHost + "/" + previous
+	}
+	return fmt.Sprintf("> Submodule %s moved from %s to %s", sc.Path, previous, sc.Repo)
+}
+
+// bumpSummary describes the version delta of an updated submodule, e.g.
+// "Bumped from v1.2.0 to v1.3.0 (minor)". The bump is only classified if both commits are
+// tagged with semantic versions
+func (sc *SubmoduleChanges) bumpSummary() string {
+	summary := fmt.Sprintf("Bumped from %s to %s", sc.oldRef(), sc.newRef())
+	if bump := bumpKind(sc.OldTag, sc.NewTag); bump != "" {
+		summary += " (" + bump + ")"
+	}
+	return summary
+}
+
+// bumpKind classifies the change between two semver tags as a major, minor or patch bump, or a
+// downgrade. The "v" prefix is optional. It returns an empty string if any of them is not a
+// semantic version
+func bumpKind(oldTag, newTag string) string {
+	oldVersion, newVersion := semverTag(oldTag), semverTag(newTag)
+	if !semver.IsValid(oldVersion) || !semver.IsValid(newVersion) {
+		return ""
+	}
+	switch {
+	case semver.Compare(newVersion, oldVersion) < 0:
+		return "downgrade"
+	case semver.Major(newVersion) != semver.Major(oldVersion):
+		return "major"
+	case semver.MajorMinor(newVersion) != semver.MajorMinor(oldVersion):
+		return "minor"
+	default:
+		return "patch"
+	}
+}
+
+// semverTag returns the tag with the "v" prefix that the semver package requires
+func semverTag(tag string) string {
+	if tag == "" || strings.HasPrefix(tag, "v") {
+		return tag
+	}
+	return "v" + tag
+}
+
+// countsSummary returns a quote line counting the changes and their distinct authors, e.g.
+// "> 37 commits from 8 contributors"
+func countsSummary(changes []Change) string {
+	authors := map[string]struct{}{}
+	for _, c := range changes {
+		if c.Author != "" {
+			authors[strings.ToLower(c.Author)] = struct{}{}
+		}
+	}
+	return fmt.Sprintf("> %s from %s",
+		plural(len(changes), "commit", "commits"), plural(len(authors), "contributor", "contributors"))
+}
+
+// plural returns the count followed by the singular or plural form of the noun
+func plural(n int, singular, pluralForm string) string {
+	if n == 1 {
+		return "1 " + singular
+	}
+	return fmt.Sprintf("%d %s", n, pluralForm)
+}
+
+// shortSHA returns the abbreviated, 7-characters form of a commit SHA
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// renderChanges renders the changes as a markdown bullet list, under a heading for each
+// .github/release.yml category if the changes were categorized, for each conventional commit type
+// in the repo-then-type Layout, or for each pull request label if GroupByLabel is set. If
+// MaxEntries is set, only the first MaxEntries changes are listed, followed by a line counting
+// the omitted ones
+func renderChanges(config Options, changes []Change) string {
+	labels, groups := []string{""}, map[string][]Change{"": changes}
+	if slices.ContainsFunc(changes, func(c Change) bool { return c.Category != "" }) {
+		labels, groups = groupByCategory(changes)
+	} else if config.Layout == LayoutRepoThenType {
+		labels, groups = groupByType(changes)
+	} else if config.GroupByLabel {
+		labels, groups = groupByLabel(changes, config.LabelPriority)
+	}
+	remaining := len(changes)
+	if config.MaxEntries > 0 {
+		remaining = config.MaxEntries
+	}
+	sections := make([]string, 0, len(labels))
+	for _, label := range labels {
+		group := groups[label]
+		if remaining <= 0 {
+			break
+		}
+		if len(group) > remaining {
+			group = group[:remaining]
+		}
+		remaining -= len(group)
+		section := markdownList(config, group)
+		if label != "" {
+			section = "### " + label + "\n" + section
+		}
+		sections = append(sections, section)
+	}
+	notes := strings.Join(sections, "\n\n")
+	if omitted := len(changes) - config.MaxEntries; config.MaxEntries > 0 && omitted > 0 {
+		notes += "\n" + omittedLine(omitted)
+	}
+	return notes
+}
+
+// omittedLine returns the bullet that replaces the changes omitted by the MaxEntries limit
+func omittedLine(omitted int) string {
+	if omitted == 1 {
+		return "* ...and 1 more commit"
+	}
+	return fmt.Sprintf("* ...and %d more commits", omitted)
+}
+
+// divergedNote explains the scope of the changes when the release and the previous one are on
+// divergent histories
+const divergedNote = "> [!NOTE]\n" +
+	"> This release and the previous one are on divergent histories. Only the commits since their " +
+	"common ancestor are listed, so changes of the previous release may be missing from this one."
+
+// verifiedBadge marks the changes whose commit signature is verified
+const verifiedBadge = "✅"
+
+// markdownEscaper escapes the characters that would be interpreted as markdown formatting or
+// HTML tags. The # character is kept, so the #123 references are still linked by GitHub. It must
+// only be applied to free text: see escapeMarkdown
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"`", "\\`",
+	`*`, `\*`,
+	`_`, `\_`,
+	`~`, `\~`,
+	`<`, `\<`,
+	`>`, `\>`,
+)
+
+// generatedReference matches the markdown links, e.g. those generated for the pull request
+// suffixes, and the owner/repo#123 references, whose repository names and URLs must be kept
+// verbatim to be linked
+var generatedReference = regexp.MustCompile(`\[[^\]]*\]\([^)\s]*\)|[\w.-]+(?:/[\w.-]+)+#\d+`)
+
+// escapeMarkdown escapes the free text of s, copying its links and references unchanged
+func escapeMarkdown(s string) string {
+	var sb strings.Builder
+	last := 0
+	for _, m := range generatedReference.FindAllStringIndex(s, -1) {
+		sb.WriteString(markdownEscaper.Replace(s[last:m[0]]))
+		sb.WriteString(s[m[0]:m[1]])
+		last = m[1]
+	}
+	sb.WriteString(markdownEscaper.Replace(s[last:]))
+	return sb.String()
+}
+
+// markdownList renders the changes as a markdown bullet list. If IncludeBody is set, the body of
+// each change is rendered as a blockquote under its bullet
+func markdownList(config Options, changes []Change) string {
+	escape := func(s string) string { return s }
+	if config.EscapeMarkdown {
+		escape = escapeMarkdown
+	}
+	lines := make([]string, 0, len(changes))
+	for _, c := range changes {
+		line := "* " + escape(c.Subject)
+		if config.ShowDates && !c.Date.IsZero() {
+			line += " (" + c.Date.UTC().Format(config.dateFormat()) + ")"
+		}
+		if config.ShowVerification && c.Verified {
+			line += " " + verifiedBadge
+		}
+		lines = append(lines, line)
+		if config.IncludeBody && c.Body != "" {
+			for _, line := range strings.Split(c.Body, "\n") {
+				lines = append(lines, strings.TrimRight("  > "+escape(line), " "))
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// dateFormat returns the Go time layout of the change dates
+func (c *Options) dateFormat() string {
+	if c.DateFormat == "" {
+		return releaseDateLayout
+	}
+	return c.DateFormat
+}
+
+// ndjsonMetadata is the leading object of the NDJSON notes, describing the run
+type ndjsonMetadata struct {
+	Type           string            `json:"type"`
+	Repository     string            `json:"repository"`
+	Tag            string            `json:"tag,omitempty"`
+	PreviousTag    string            `json:"previous_tag,omitempty"`
+	ReleaseDate    string            `json:"release_date,omitempty"`
+	Commit         string            `json:"commit"`
+	PreviousCommit string            `json:"previous_commit"`
+	Submodules     []ndjsonSubmodule `json:"submodules,omitempty"`
+}
+
+type ndjsonSubmodule struct {
+	Repo         string `json:"repo"`
+	PreviousRepo string `json:"previous_repo,omitempty"`
+	Path         string `json:"path"`
+	State        string `json:"state"`
+	OldTag       string `json:"old_tag,omitempty"`
+	NewTag       string `json:"new_tag,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// ndjsonChange adds a type field to each change, so consumers can tell it apart from the
+// metadata object
+type ndjsonChange struct {
+	Type string `json:"type"`
+	Change
+}
+
+// WriteNDJSON writes the notes of the Result as newline-delimited JSON, as the ndjson Format does.
+// Each record is written to w as soon as it is encoded, so it can be streamed to the consumer
+func WriteNDJSON(w io.Writer, config Options, result Result) error {
+	return writeNDJSON(w, ndjsonMetadata{
+		Repository:     config.Repository,
+		Tag:            config.Tag,
+		PreviousTag:    result.PreviousTag,
+		ReleaseDate:    result.ReleaseDate,
+		Commit:         result.Commit,
+		PreviousCommit: result.PreviousCommit,
+	}, result.ReleaseNotes)
+}
+
+// writeNDJSON writes the notes as newline-delimited JSON: a leading metadata object followed by
+// one object per change, each one written to w in its own call
+func writeNDJSON(w io.Writer, meta ndjsonMetadata, rn ReleaseNotes) error {
+	submodules := flattenSubmodules(rn.Submodules)
+	for _, sm := range submodules {
+		nsm := ndjsonSubmodule{Repo: sm.Repo, PreviousRepo: sm.PreviousRepo, Path: sm.Path, State: sm.State.String(), OldTag: sm.OldTag, NewTag: sm.NewTag}
+		if sm.Err != nil {
+			nsm.Error = sm.Err.Error()
+		}
+		meta.Submodules = append(meta.Submodules, nsm)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	meta.Type = "metadata"
+	if err := enc.Encode(meta); err != nil {
+		return err
+	}
+	changes := rn.Changes
+	for _, sm := range submodules {
+		changes = append(changes[:len(changes):len(changes)], sm.Changes...)
+	}
+	for _, c := range changes {
+		if err := enc.Encode(ndjsonChange{Type: "change", Change: c}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChangelogEntry is an item of the flat list of changes of the changelog_entries output, for the
+// release bots that post the changes individually
+type ChangelogEntry struct {
+	SHA      string `json:"sha"`
+	ShortSHA string `json:"shortSha"`
+	Message  string `json:"message"`
+	Author   string `json:"author"`
+	PRNumber int    `json:"prNumber"`
+	Repo     string `json:"repo"`
+}
+
+// ChangelogEntries returns the changes of the main repository and all the submodules as a flat
+// list, regardless of how they are grouped in the markdown notes
+func (rn *ReleaseNotes) ChangelogEntries() []ChangelogEntry {
+	changes := rn.Changes
+	for _, sm := range flattenSubmodules(rn.Submodules) {
+		changes = append(changes[:len(changes):len(changes)], sm.Changes...)
+	}
+	entries := make([]ChangelogEntry, 0, len(changes))
+	for _, c := range changes {
+		entries = append(entries, ChangelogEntry{
+			SHA:      c.SHA,
+			ShortSHA: shortSHA(c.SHA),
+			Message:  c.Subject,
+			Author:   c.Author,
+			PRNumber: c.PR,
+			Repo:     c.Repo,
+		})
+	}
+	return entries
+}
@@ -0,0 +1,465 @@
+package releasenotes
+
+import (
+	"bufio"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteNDJSON(t *testing.T) {
+	changes := []Change{
+		{Repo: "owner/repo", SHA: "aaaaaaa", Subject: "Add feature (#12)", Author: "mariomac", PR: 12},
+		{Repo: "owner/repo", SHA: "bbbbbbb", Subject: `Fix "quoted" <html> issue`},
+	}
+	smChanges := &SubmoduleChanges{
+		Repo: "other/lib", Path: "lib", State: SubmoduleUpdated,
+		Changes: []Change{{Repo: "other/lib", SHA: "ccccccc", Subject: "Bump lib", Author: "someone"}},
+	}
+	sb := strings.Builder{}
+	if err := writeNDJSON(&sb, ndjsonMetadata{Repository: "owner/repo", Tag: "v1.1.0"},
+		ReleaseNotes{Changes: changes, Submodules: []*SubmoduleChanges{smChanges}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var types []string
+	repos := map[string]int{}
+	scanner := bufio.NewScanner(strings.NewReader(sb.String()))
+	for scanner.Scan() {
+		line := map[string]any{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", scanner.Text(), err)
+		}
+		types = append(types, line["type"].(string))
+		if line["type"] == "change" {
+			repos[line["repo"].(string)]++
+		}
+	}
+	if len(types) != 1+len(changes)+len(smChanges.Changes) {
+		t.Fatalf("got %d lines, want %d", len(types), 1+len(changes)+len(smChanges.Changes))
+	}
+	if types[0] != "metadata" {
+		t.Errorf("first line type = %q, want metadata", types[0])
+	}
+	if repos["owner/repo"] != len(changes) || repos["other/lib"] != len(smChanges.Changes) {
+		t.Errorf("unexpected changes per repository: %v", repos)
+	}
+	if len(changes) != 2 {
+		t.Errorf("main changes slice was modified: %v", changes)
+	}
+}
+
+func TestChangelogEntries(t *testing.T) {
+	rn := ReleaseNotes{
+		Changes: []Change{{Repo: "owner/repo", SHA: "0123456789abcdef", Subject: "Add feature (#3)", Author: "dev", PR: 3}},
+		Submodules: []*SubmoduleChanges{{
+			Repo: "other/lib", State: SubmoduleUpdated,
+			Changes: []Change{{Repo: "other/lib", SHA: "fedcba9876543210", Subject: "Fix crash"}},
+		}},
+	}
+	entries, err := json.Marshal(rn.ChangelogEntries())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"sha":"0123456789abcdef","shortSha":"0123456","message":"Add feature (#3)","author":"dev","prNumber":3,"repo":"owner/repo"},` +
+		`{"sha":"fedcba9876543210","shortSha":"fedcba9","message":"Fix crash","author":"","prNumber":0,"repo":"other/lib"}]`
+	if string(entries) != want {
+		t.Errorf("ChangelogEntries() = %s, want %s", entries, want)
+	}
+	if entries, _ := json.Marshal((&ReleaseNotes{}).ChangelogEntries()); string(entries) != "[]" {
+		t.Errorf("expected an empty array without changes, got %s", entries)
+	}
+}
+
+func TestSubmodulePointerSummary(t *testing.T) {
+	sc := &SubmoduleChanges{
+		Repo: "other/lib", Path: "vendor/lib", State: SubmoduleUpdated,
+		Old:     "0123456789abcdef0123456789abcdef01234567",
+		New:     "fedcba9876543210fedcba9876543210fedcba98",
+		Changes: []Change{{Subject: "First"}, {Subject: "Second"}},
+	}
+	want := "\n## Changes from other/lib:\n" +
+		"Submodule vendor/lib updated from 0123456 to fedcba9 (2 commits)\n\n" +
+		"* First\n* Second\n"
+	if got := sc.render(Options{SubmodulePointerSummary: true}); got != want {
+		t.Errorf("render() = %q, want %q", got, want)
+	}
+	if got := sc.render(Options{}); strings.Contains(got, "updated from") {
+		t.Errorf("unexpected pointer summary when disabled: %q", got)
+	}
+}
+
+func TestSubmoduleBumpSummary(t *testing.T) {
+	sc := &SubmoduleChanges{
+		Repo: "other/lib", State: SubmoduleUpdated,
+		Old: "0123456789abcdef", New: "fedcba9876543210", OldTag: "v1.2.0", NewTag: "v1.3.0",
+		Changes: []Change{{Subject: "Fix"}},
+	}
+	want := "\n## Changes from other/lib:\nBumped from v1.2.0 to v1.3.0 (minor)\n\n* Fix\n"
+	if got := sc.render(Options{SubmoduleBumpSummary: true}); got != want {
+		t.Errorf("render() = %q, want %q", got, want)
+	}
+	if got := sc.render(Options{}); strings.Contains(got, "Bumped") {
+		t.Errorf("unexpected bump summary when disabled: %q", got)
+	}
+
+	sc.OldTag = ""
+	if got, want := sc.bumpSummary(), "Bumped from 0123456 to v1.3.0"; got != want {
+		t.Errorf("bumpSummary() = %q, want %q", got, want)
+	}
+}
+
+func TestBumpKind(t *testing.T) {
+	tests := []struct{ old, new, want string }{
+		{"v1.2.0", "v2.0.0", "major"},
+		{"v1.2.0", "v1.3.0", "minor"},
+		{"v1.2.0", "v1.2.1", "patch"},
+		{"1.2.0", "1.2.1", "patch"},
+		{"v1.3.0-rc.1", "v1.3.0", "patch"},
+		{"v1.3.0", "v1.2.0", "downgrade"},
+		{"v1.2.0", "release-2024", ""},
+		{"", "v1.2.0", ""},
+	}
+	for _, tt := range tests {
+		if got := bumpKind(tt.old, tt.new); got != tt.want {
+			t.Errorf("bumpKind(%q, %q) = %q, want %q", tt.old, tt.new, got, tt.want)
+		}
+	}
+}
+
+func TestRenderMarkdown_LinkSections(t *testing.T) {
+	rn := ReleaseNotes{
+		Changes:    []Change{{Subject: "Add feature"}},
+		CompareURL: "https://github.com/owner/repo/compare/old...new",
+		Submodules: []*SubmoduleChanges{
+			{Host: "github.com", Repo: "other/lib", State: SubmoduleUpdated, Old: "aaa", New: "bbb", Changes: []Change{{Subject: "Fix"}}},
+			{Host: "gitlab.com", Repo: "group/sub/tool", Path: "tool", State: SubmoduleAdded, New: "ccc", Changes: []Change{{Subject: "Init"}}},
+			{Host: "github.com", Repo: "other/gone", Path: "gone", State: SubmoduleRemoved, Old: "ddd"},
+		},
+	}
+	want := "## Changes from [owner/repo](https://github.com/owner/repo/compare/old...new):\n* Add feature\n\n" +
+		"## Changes from [other/lib](https://github.com/other/lib/compare/aaa...bbb):\n* Fix\n\n" +
+		"## Changes from [group/sub/tool](https://gitlab.com/group/sub/tool/-/tree/ccc) (new submodule tool):\n* Init\n\n" +
+		"## Changes from other/gone:\nSubmodule gone removed\n"
+	if got := renderMarkdown(Options{Repository: "owner/repo", LinkSections: true}, rn); got != want {
+		t.Errorf("renderMarkdown() = %q, want %q", got, want)
+	}
+	if got := renderMarkdown(Options{Repository: "owner/repo"}, rn); strings.Contains(got, "](") {
+		t.Errorf("unexpected links when disabled: %q", got)
+	}
+}
+
+func TestRenderMarkdown_Diverged(t *testing.T) {
+	notes := renderMarkdown(Options{Repository: "owner/repo"}, ReleaseNotes{
+		Changes:  []Change{{Subject: "Fix"}},
+		Diverged: true,
+	})
+	want := "## Changes from owner/repo:\n" + divergedNote + "\n\n* Fix\n"
+	if notes != want {
+		t.Errorf("renderMarkdown() = %q, want %q", notes, want)
+	}
+}
+
+func TestRenderMarkdown_ShowSummary(t *testing.T) {
+	notes := renderMarkdown(Options{Repository: "owner/repo", ShowSummary: true, MaxEntries: 1}, ReleaseNotes{
+		Changes: []Change{{Subject: "First", Author: "alice"}, {Subject: "Second", Author: "Alice"}},
+		Submodules: []*SubmoduleChanges{{
+			Repo: "other/lib", State: SubmoduleUpdated,
+			Changes: []Change{{Subject: "Fix", Author: "bob"}},
+		}},
+	})
+	// the summary counts all the changes, not only the rendered entries
+	want := "## Changes from owner/repo:\n> 2 commits from 1 contributor\n\n* First\n* ...and 1 more commit\n\n" +
+		"## Changes from other/lib:\n> 1 commit from 1 contributor\n\n* Fix\n"
+	if notes != want {
+		t.Errorf("renderMarkdown() = %q, want %q", notes, want)
+	}
+}
+
+func TestRenderMarkdown_ShowDates(t *testing.T) {
+	rn := ReleaseNotes{Changes: []Change{
+		{Subject: "Fix", Date: time.Date(2024, 6, 1, 23, 30, 0, 0, time.FixedZone("", -2*3600)), Verified: true},
+		{Subject: "Undated"},
+	}}
+	notes := renderMarkdown(Options{Repository: "owner/repo", ShowDates: true, ShowVerification: true}, rn)
+	want := "## Changes from owner/repo:\n* Fix (2024-06-02) ✅\n* Undated\n"
+	if notes != want {
+		t.Errorf("renderMarkdown() = %q, want %q", notes, want)
+	}
+	notes = renderMarkdown(Options{Repository: "owner/repo", ShowDates: true, DateFormat: "Jan 2, 2006 15:04"}, rn)
+	if !strings.Contains(notes, "* Fix (Jun 2, 2024 01:30)\n") {
+		t.Errorf("unexpected date format: %q", notes)
+	}
+}
+
+func TestRenderMarkdown_CollapseSubmodules(t *testing.T) {
+	notes := renderMarkdown(Options{Repository: "owner/repo", CollapseSubmodules: true}, ReleaseNotes{
+		Changes: []Change{{Subject: "Add feature"}},
+		Submodules: []*SubmoduleChanges{
+			{Repo: "other/lib", State: SubmoduleUpdated, Old: "aaa", New: "bbb", Changes: []Change{{Subject: "Fix"}, {Subject: "Docs"}}},
+			{Repo: "other/gone", Path: "gone", State: SubmoduleRemoved, Old: "ddd"},
+		},
+	})
+	want := "## Changes from owner/repo:\n* Add feature\n\n" +
+		"<details>\n<summary>other/lib (2 changes)</summary>\n\n* Fix\n* Docs\n\n</details>\n\n" +
+		"<details>\n<summary>other/gone (removed)</summary>\n\nSubmodule gone removed\n\n</details>\n"
+	if notes != want {
+		t.Errorf("renderMarkdown() = %q, want %q", notes, want)
+	}
+}
+
+func TestSubmoduleHeadingTemplate(t *testing.T) {
+	sc := &SubmoduleChanges{
+		Name: "lib", Repo: "other/lib", Path: "vendor/lib", State: SubmoduleUpdated, Depth: 1,
+		Old:     "0123456789abcdef0123456789abcdef01234567",
+		New:     "fedcba9876543210fedcba9876543210fedcba98",
+		Changes: []Change{{Subject: "First"}},
+	}
+	want := "\n### 📦 lib (0123456 → fedcba9)\n* First\n"
+	if got := sc.render(Options{SubmoduleHeadingTemplate: "📦 {{name}} ({{old}} → {{new}})"}); got != want {
+		t.Errorf("render() = %q, want %q", got, want)
+	}
+
+	sc.State = SubmoduleAdded
+	want = "\n### Changes from other/lib (new submodule vendor/lib):\n* First\n"
+	if got := sc.render(Options{}); got != want {
+		t.Errorf("default heading changed: render() = %q, want %q", got, want)
+	}
+}
+
+func TestRenderMarkdown_SectionTitlePrefix(t *testing.T) {
+	rn := ReleaseNotes{
+		Changes: []Change{{Subject: "Add feature"}},
+		Submodules: []*SubmoduleChanges{
+			{Repo: "other/lib", State: SubmoduleUpdated, Old: "aaa", New: "bbb", Changes: []Change{{Subject: "Fix"}}},
+			{Repo: "other/tool", Path: "tool", State: SubmoduleAdded, New: "ccc", Changes: []Change{{Subject: "Init"}}},
+		},
+	}
+	want := "## Änderungen in owner/repo:\n* Add feature\n\n" +
+		"## Änderungen in other/lib:\n* Fix\n\n" +
+		"## Änderungen in other/tool (new submodule tool):\n* Init\n"
+	if got := renderMarkdown(Options{Repository: "owner/repo", SectionTitlePrefix: "Änderungen in"}, rn); got != want {
+		t.Errorf("renderMarkdown() = %q, want %q", got, want)
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("GITHUB_RUN_ID", "12345")
+	t.Setenv("GITHUB_TOKEN", "secret")
+	t.Setenv("INPUT_GITHUB_TOKEN", "secret")
+
+	notes := renderMarkdown(Options{
+		Repository: "owner/repo",
+		Header:     "Generated by run $GITHUB_RUN_ID",
+		Footer:     "Token: $GITHUB_TOKEN ${INPUT_GITHUB_TOKEN} $UNDEFINED",
+	}, ReleaseNotes{Changes: []Change{{Subject: "Add feature"}}})
+
+	want := "Generated by run 12345\n\n" +
+		"## Changes from owner/repo:\n* Add feature\n" +
+		"\nToken: $GITHUB_TOKEN ${INPUT_GITHUB_TOKEN} $UNDEFINED\n"
+	if notes != want {
+		t.Errorf("renderMarkdown() = %q, want %q", notes, want)
+	}
+
+	for in, want := range map[string]string{
+		"Costs $50":                    "Costs $50",
+		"$HOME and ${HOME}":            "$HOME and ${HOME}",
+		"$$":                           "$$",
+		"a ${ b":                       "a ${ b",
+		"trailing $":                   "trailing $",
+		"run ${GITHUB_RUN_ID}!":        "run 12345!",
+		"run $GITHUB_RUN_ID.":          "run 12345.",
+		"$$GITHUB_RUN_ID":              "$12345",
+		"${GITHUB_RUN_ID unterminated": "${GITHUB_RUN_ID unterminated",
+	} {
+		if got := expandEnv(in); got != want {
+			t.Errorf("expandEnv(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRenderChanges_MaxEntries(t *testing.T) {
+	changes := []Change{
+		{Subject: "Fix crash", Labels: []string{"bug"}},
+		{Subject: "Add feature", Labels: []string{"enhancement"}},
+		{Subject: "Direct push"},
+		{Subject: "Another fix", Labels: []string{"bug"}},
+	}
+	tests := []struct {
+		name   string
+		config Options
+		want   string
+	}{{
+		name:   "plain list",
+		config: Options{MaxEntries: 2},
+		want:   "* Fix crash\n* Add feature\n* ...and 2 more commits",
+	}, {
+		name:   "the most relevant groups survive",
+		config: Options{MaxEntries: 3, GroupByLabel: true, LabelPriority: []string{"bug"}},
+		want:   "### bug\n* Fix crash\n* Another fix\n\n### enhancement\n* Add feature\n* ...and 1 more commit",
+	}, {
+		name:   "no truncation below the limit",
+		config: Options{MaxEntries: 4},
+		want:   "* Fix crash\n* Add feature\n* Direct push\n* Another fix",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderChanges(tt.config, changes); got != tt.want {
+				t.Errorf("renderChanges() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderMarkdown_MainBody(t *testing.T) {
+	notes := renderMarkdown(Options{Repository: "owner/repo"}, ReleaseNotes{
+		Changes:  []Change{{Subject: "Add feature"}},
+		MainBody: "### Features\n* Add feature by @dev in #3",
+	})
+	want := "## Changes from owner/repo:\n### Features\n* Add feature by @dev in #3\n"
+	if notes != want {
+		t.Errorf("renderMarkdown() = %q, want %q", notes, want)
+	}
+}
+
+func TestRenderMarkdown_InitialRelease(t *testing.T) {
+	notes := renderMarkdown(Options{Repository: "owner/repo"}, ReleaseNotes{
+		Changes:        []Change{{Subject: "Initial commit"}},
+		InitialRelease: true,
+	})
+	want := "## Initial release\n* Initial commit\n"
+	if notes != want {
+		t.Errorf("renderMarkdown() = %q, want %q", notes, want)
+	}
+}
+
+func TestRenderMarkdown_HeaderTemplate(t *testing.T) {
+	notes := renderMarkdown(Options{
+		Repository: "owner/repo", Tag: "v1.3.0",
+		Header: "## {{tag}} - {{release_date}}", Footer: "Generated on {{date}}",
+	}, ReleaseNotes{Changes: []Change{{Subject: "Add feature"}}, ReleaseDate: "2024-06-01", GenerationDate: "2024-06-03"})
+	want := "## v1.3.0 - 2024-06-01\n\n## Changes from owner/repo:\n* Add feature\n\nGenerated on 2024-06-03\n"
+	if notes != want {
+		t.Errorf("renderMarkdown() = %q, want %q", notes, want)
+	}
+}
+
+func TestRenderMarkdown_NoChanges(t *testing.T) {
+	notes := renderMarkdown(Options{Repository: "owner/repo", Footer: "Bye"}, ReleaseNotes{})
+	want := "No changes since previous release\n\nBye\n"
+	if notes != want {
+		t.Errorf("renderMarkdown() = %q, want %q", notes, want)
+	}
+}
+
+func TestRenderChanges_IncludeBody(t *testing.T) {
+	changes := []Change{
+		{Subject: "Add feature", Body: "Explains why\n\nand how"},
+		{Subject: "Fix typo"},
+	}
+	want := "* Add feature\n  > Explains why\n  >\n  > and how\n* Fix typo"
+	if got := renderChanges(Options{IncludeBody: true}, changes); got != want {
+		t.Errorf("renderChanges() = %q, want %q", got, want)
+	}
+	if got := renderChanges(Options{}, changes); got != "* Add feature\n* Fix typo" {
+		t.Errorf("body must not be rendered by default, got %q", got)
+	}
+}
+
+func TestRenderChanges_ShowVerification(t *testing.T) {
+	changes := []Change{{Subject: "Signed", Verified: true}, {Subject: "Unsigned"}}
+	if got, want := renderChanges(Options{ShowVerification: true}, changes), "* Signed ✅\n* Unsigned"; got != want {
+		t.Errorf("renderChanges() = %q, want %q", got, want)
+	}
+	if got := renderChanges(Options{}, changes); strings.Contains(got, verifiedBadge) {
+		t.Errorf("badge must not be rendered by default, got %q", got)
+	}
+}
+
+func TestRenderChanges_EscapeMarkdown(t *testing.T) {
+	changes := []Change{
+		{Subject: "Use `fmt` in *all* my_func<T> (#12)", Body: "> not a quote"},
+		{Subject: "Fix owner/lib#3 ([#4](https://github.com/owner/repo/pull/4))"},
+	}
+	want := "* Use \\`fmt\\` in \\*all\\* my\\_func\\<T\\> (#12)\n  > \\> not a quote\n" +
+		"* Fix owner/lib#3 ([#4](https://github.com/owner/repo/pull/4))"
+	if got := renderChanges(Options{EscapeMarkdown: true, IncludeBody: true}, changes); got != want {
+		t.Errorf("renderChanges() = %q, want %q", got, want)
+	}
+	if got := renderChanges(Options{}, changes[:1]); got != "* "+changes[0].Subject {
+		t.Errorf("subjects must not be escaped by default, got %q", got)
+	}
+}
+
+func TestRenderChanges_EscapeMarkdownKeepsReferences(t *testing.T) {
+	changes := []Change{
+		{Subject: "Fix snake_case in owner/re_po#12 and my_org/sub_group/re_po#3"},
+		{Subject: "Add *flag* ([#4](https://github.com/my_org/re_po/pull/4))"},
+		{Subject: "Bump [my_org/lib#5](https://gitlab.com/my_org/lib/-/issues/5) to_do"},
+	}
+	want := "* Fix snake\\_case in owner/re_po#12 and my_org/sub_group/re_po#3\n" +
+		"* Add \\*flag\\* ([#4](https://github.com/my_org/re_po/pull/4))\n" +
+		"* Bump [my_org/lib#5](https://gitlab.com/my_org/lib/-/issues/5) to\\_do"
+	if got := renderChanges(Options{EscapeMarkdown: true}, changes); got != want {
+		t.Errorf("renderChanges() = %q, want %q", got, want)
+	}
+}
+
+func TestRenderMarkdown_SectionOrder(t *testing.T) {
+	rn := ReleaseNotes{
+		Changes: []Change{{Subject: "Add feature"}},
+		Submodules: []*SubmoduleChanges{
+			{Repo: "other/lib", State: SubmoduleUpdated, Changes: []Change{{Subject: "Fix lib"}}},
+			// updated submodules without changes are omitted
+			{Repo: "other/empty", State: SubmoduleUpdated},
+		},
+	}
+	want := "## Changes from other/lib:\n* Fix lib\n\n## Changes from owner/repo:\n* Add feature\n"
+	config := Options{Repository: "owner/repo", SectionOrder: []string{SectionSubmodule, SectionMain}}
+	if got := renderMarkdown(config, rn); got != want {
+		t.Errorf("renderMarkdown() = %q, want %q", got, want)
+	}
+
+	// the main section is omitted when only the submodules changed
+	rn.Changes = nil
+	want = "## Changes from other/lib:\n* Fix lib\n"
+	if got := renderMarkdown(Options{Repository: "owner/repo"}, rn); got != want {
+		t.Errorf("renderMarkdown() = %q, want %q", got, want)
+	}
+}
+
+// writesRecorder keeps each of the writes it receives
+type writesRecorder struct {
+	writes []string
+}
+
+func (w *writesRecorder) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, string(p))
+	return len(p), nil
+}
+
+func TestWriteNDJSON_StreamsRecords(t *testing.T) {
+	result := Result{
+		ReleaseNotes: ReleaseNotes{Changes: []Change{
+			{Repo: "owner/repo", SHA: "aaaaaaa", Subject: "First"},
+			{Repo: "owner/repo", SHA: "bbbbbbb", Subject: "Second"},
+		}},
+		Commit: "bbbbbbb", PreviousTag: "v1.0.0",
+	}
+	w := &writesRecorder{}
+	if err := WriteNDJSON(w, Options{Repository: "owner/repo", Tag: "v1.1.0"}, result); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(w.writes) != 3 {
+		t.Fatalf("got %d writes, want one per record: %q", len(w.writes), w.writes)
+	}
+	for _, line := range w.writes {
+		if strings.Count(line, "\n") != 1 || !strings.HasSuffix(line, "\n") {
+			t.Errorf("write %q is not a single record", line)
+		}
+	}
+	if !strings.Contains(w.writes[0], `"previous_tag":"v1.0.0"`) {
+		t.Errorf("unexpected metadata record: %s", w.writes[0])
+	}
+}
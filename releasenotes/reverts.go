@@ -0,0 +1,63 @@
+package releasenotes
+
+import (
+	"cmp"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// matches the subject of the revert commits generated by git and GitHub, capturing the subject
+// of the reverted commit, e.g. `Revert "feat: add parser"` or `Revert "feat: add parser (#12)" (#13)`
+var revertSubject = regexp.MustCompile(`^Revert "(.*)"(?:\s*\(#\d+\))?\s*$`)
+
+// collapseReverts removes the revert commits together with the commits they revert, when both
+// are in the changes. The reverted commit is found by the quoted subject of the revert. Reverts
+// of reverts are matched first, so reverting a revert keeps the original change
+func collapseReverts(changes []Change) []Change {
+	firstLines := make([]string, len(changes))
+	reverts := map[int]string{}
+	for i, c := range changes {
+		firstLines[i], _, _ = strings.Cut(c.Subject, "\n")
+		if m := revertSubject.FindStringSubmatch(firstLines[i]); m != nil {
+			reverts[i] = m[1]
+		}
+	}
+	if len(reverts) == 0 {
+		return changes
+	}
+	// the most nested reverts have the longest subjects
+	order := make([]int, 0, len(reverts))
+	for i := range reverts {
+		order = append(order, i)
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		return cmp.Or(len(firstLines[b])-len(firstLines[a]), a-b)
+	})
+	removed := map[int]bool{}
+	for _, i := range order {
+		if removed[i] {
+			continue
+		}
+		target := -1
+		for j, subject := range firstLines {
+			if j != i && !removed[j] && subject == reverts[i] {
+				target = j
+				break
+			}
+		}
+		if target < 0 {
+			continue
+		}
+		slog.Debug("collapsing revert", "revert", changes[i].SHA, "reverted", changes[target].SHA)
+		removed[i], removed[target] = true, true
+	}
+	var result []Change
+	for i, c := range changes {
+		if !removed[i] {
+			result = append(result, c)
+		}
+	}
+	return result
+}
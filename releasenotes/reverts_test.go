@@ -0,0 +1,59 @@
+package releasenotes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCollapseReverts(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		subjects []string
+		want     []string
+	}{
+		{
+			name:     "matched",
+			subjects: []string{"feat: add parser (#12)", "fix: typo", `Revert "feat: add parser (#12)" (#13)`},
+			want:     []string{"fix: typo"},
+		},
+		{
+			name:     "newest first",
+			subjects: []string{`Revert "feat: add parser"`, "fix: typo", "feat: add parser"},
+			want:     []string{"fix: typo"},
+		},
+		{
+			name:     "unmatched",
+			subjects: []string{"fix: typo", `Revert "feat: released before"`},
+			want:     []string{"fix: typo", `Revert "feat: released before"`},
+		},
+		{
+			name:     "each revert cancels one commit",
+			subjects: []string{"chore: bump", "chore: bump", `Revert "chore: bump"`},
+			want:     []string{"chore: bump"},
+		},
+		{
+			name:     "revert of a revert",
+			subjects: []string{"feat: add parser", `Revert "feat: add parser"`, `Revert "Revert "feat: add parser""`},
+			want:     []string{"feat: add parser"},
+		},
+		{
+			name:     "paragraph subject",
+			subjects: []string{"feat: add parser\nwith details", "Revert \"feat: add parser\"\nThis reverts commit 0123456."},
+			want:     nil,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			changes := make([]Change, 0, len(tc.subjects))
+			for _, s := range tc.subjects {
+				changes = append(changes, Change{Subject: s})
+			}
+			var got []string
+			for _, c := range collapseReverts(changes) {
+				got = append(got, c.Subject)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("collapseReverts() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
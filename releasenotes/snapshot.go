@@ -0,0 +1,62 @@
+package releasenotes
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	ModeGenerate = "generate"
+	ModeVerify   = "verify"
+)
+
+// VerifySnapshot returns an error containing a line diff if the generated notes differ from
+// the contents of the snapshot file
+func VerifySnapshot(path, notes string) error {
+	snapshot, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading snapshot: %w", err)
+	}
+	if string(snapshot) == notes {
+		return nil
+	}
+	return fmt.Errorf("release notes differ from snapshot %s:\n%s", path, lineDiff(string(snapshot), notes))
+}
+
+// lineDiff returns the lines that need to be removed from (-) and added to (+) the old text
+// to get the new text, computed from their longest common subsequence of lines
+func lineDiff(oldText, newText string) string {
+	a, b := strings.Split(oldText, "\n"), strings.Split(newText, "\n")
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	sb := strings.Builder{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			sb.WriteString("  " + a[i] + "\n")
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			sb.WriteString("+ " + b[j] + "\n")
+			j++
+		default:
+			sb.WriteString("- " + a[i] + "\n")
+			i++
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,41 @@
+package releasenotes
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifySnapshot(t *testing.T) {
+	notes := "## Changes from owner/repo:\n* Add feature\n* Fix bug\n"
+	path := filepath.Join(t.TempDir(), "CHANGELOG.md")
+
+	t.Run("matching snapshot", func(t *testing.T) {
+		if err := os.WriteFile(path, []byte(notes), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifySnapshot(path, notes); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("mismatching snapshot", func(t *testing.T) {
+		outdated := "## Changes from owner/repo:\n* Add feature\n* Old entry\n"
+		if err := os.WriteFile(path, []byte(outdated), 0644); err != nil {
+			t.Fatal(err)
+		}
+		err := VerifySnapshot(path, notes)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		for _, line := range []string{"  * Add feature\n", "- * Old entry\n", "+ * Fix bug\n"} {
+			if !strings.Contains(err.Error(), line) {
+				t.Errorf("expected diff line %q in error: %v", line, err)
+			}
+		}
+		// the snapshot must not be overwritten
+		if content, _ := os.ReadFile(path); string(content) != outdated {
+			t.Errorf("snapshot was modified: %q", content)
+		}
+	})
+}
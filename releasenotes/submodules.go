@@ -0,0 +1,656 @@
+package releasenotes
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/go-github/v57/github"
+)
+
+// SubmoduleState describes how a submodule changed between the previous and the current tag
+type SubmoduleState int
+
+const (
+	// SubmoduleUpdated means that the submodule is present in both tags
+	SubmoduleUpdated SubmoduleState = iota
+	// SubmoduleAdded means that the submodule is only present in the current tag
+	SubmoduleAdded
+	// SubmoduleRemoved means that the submodule is only present in the previous tag
+	SubmoduleRemoved
+)
+
+func (s SubmoduleState) String() string {
+	switch s {
+	case SubmoduleAdded:
+		return "added"
+	case SubmoduleRemoved:
+		return "removed"
+	default:
+		return "updated"
+	}
+}
+
+// submoduleCommits contains the gitlink SHAs of a submodule in the previous and current tags.
+// Old is empty if the submodule has been added, and New is empty if it has been removed.
+type submoduleCommits struct {
+	Old   string
+	New   string
+	State SubmoduleState
+}
+
+// SubmoduleChanges is the structured result of comparing a submodule between two tags
+type SubmoduleChanges struct {
+	// Name of the submodule in the .gitmodules file
+	Name string
+	// Host of the submodule repository, e.g. github.com
+	Host string
+	Repo string
+	// PreviousHost and PreviousRepo are the repository of the submodule in the previous commit,
+	// if it has moved since then. The commits are compared in the current repository
+	PreviousHost string
+	PreviousRepo string
+	Path         string
+	State        SubmoduleState
+	Old          string
+	New          string
+	// OldTag and NewTag are the names of the tags pointing to the Old and New commits, if any
+	OldTag  string
+	NewTag  string
+	Changes []Change
+	// Uncompared is set when the Old and New commits couldn't be compared, e.g. because Old was
+	// force-pushed away, so the Changes are the commits reachable from New since the date of Old
+	Uncompared bool
+	// DiffStat is the size of the changes between the Old and New commits, if ShowDiffstat is set
+	DiffStat *DiffStat
+	// Depth is the nesting level of the submodule: 0 for the submodules of the main repository,
+	// 1 for the submodules of these submodules, and so on
+	Depth int
+	// Submodules contains the changes of the nested submodules, up to the RecursiveDepth
+	Submodules []*SubmoduleChanges
+	// Err is the error that prevented resolving (part of) the submodule changes, if any
+	Err error
+}
+
+// oldRef returns the tag of the previous submodule commit, or its short SHA if it isn't tagged
+func (sc *SubmoduleChanges) oldRef() string {
+	if sc.OldTag != "" {
+		return sc.OldTag
+	}
+	return shortSHA(sc.Old)
+}
+
+// newRef returns the tag of the current submodule commit, or its short SHA if it isn't tagged
+func (sc *SubmoduleChanges) newRef() string {
+	if sc.NewTag != "" {
+		return sc.NewTag
+	}
+	return shortSHA(sc.New)
+}
+
+// compareURL returns the URL of the web view that compares the previous and current submodule
+// commits, or that browses the current commit of an added submodule. It returns an empty string
+// if the submodule has no current commit
+func (sc *SubmoduleChanges) compareURL() string {
+	if sc.New == "" {
+		return ""
+	}
+	base, separator := serverURL()+"/"+sc.Repo, "/"
+	if sc.Host == gitlabHost {
+		base, separator = "https://"+gitlabHost+"/"+sc.Repo, "/-/"
+	}
+	if sc.State == SubmoduleAdded || sc.Old == "" {
+		return base + separator + "tree/" + sc.New
+	}
+	return base + separator + "compare/" + sc.Old + "..." + sc.New
+}
+
+// flattenSubmodules returns the submodule changes and all their nested submodule changes,
+// in depth-first order
+func flattenSubmodules(smChanges []*SubmoduleChanges) []*SubmoduleChanges {
+	var result []*SubmoduleChanges
+	for _, sm := range smChanges {
+		result = append(result, sm)
+		result = append(result, flattenSubmodules(sm.Submodules)...)
+	}
+	return result
+}
+
+// getChangesForSubmodules returns the changes for each submodule declared in the .gitmodules file
+// of the current commit, followed by the submodules that have been removed since the previous commit
+func (rnw *ReleaseNotesWriter) getChangesForSubmodules(
+	ctx context.Context, owner string, repo string, commit string, prevCommit string,
+) ([]*SubmoduleChanges, error) {
+	return rnw.getNestedChangesForSubmodules(ctx, owner, repo, commit, prevCommit, 0, []string{owner + "/" + repo})
+}
+
+// getNestedChangesForSubmodules returns the changes for the submodules of the given repository,
+// which is at the given nesting depth. ancestors contains the repositories that have been already
+// traversed to reach it, so submodule cycles aren't followed
+func (rnw *ReleaseNotesWriter) getNestedChangesForSubmodules(
+	ctx context.Context, owner, repo, commit, prevCommit string, depth int, ancestors []string,
+) ([]*SubmoduleChanges, error) {
+	pathRepo := rnw.getSubmodulePathRepo
+	if depth == 0 && rnw.config.SubmoduleLockfile != "" {
+		// the dependencies pinned in the lockfile replace the submodules of the main repository
+		pathRepo = rnw.getLockfileSubmodules
+	}
+	submodules, err := pathRepo(ctx, owner, repo, commit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get submodule paths and repositories: %w", err)
+	}
+	prevSubmodules, err := pathRepo(ctx, owner, repo, prevCommit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get previous submodule paths and repositories: %w", err)
+	}
+	declared := map[string]int{}
+	for i, sm := range submodules {
+		declared[sm.Path] = i
+	}
+	for _, sm := range prevSubmodules {
+		i, ok := declared[sm.Path]
+		if !ok {
+			// the submodule has been removed, so there is no tracked branch to follow
+			sm.Branch = ""
+			submodules = append(submodules, sm)
+			continue
+		}
+		if current := &submodules[i]; sm.Host != current.Host || !strings.EqualFold(sm.Repo, current.Repo) {
+			slog.Info("submodule repository has moved", "path", sm.Path, "previous", sm.Host+"/"+sm.Repo,
+				"current", current.Host+"/"+current.Repo)
+			current.PreviousHost, current.PreviousRepo = sm.Host, sm.Repo
+		}
+	}
+	if len(submodules) == 0 {
+		slog.Info("no submodule repository found")
+		return nil, nil
+	}
+	if depth == 0 && len(rnw.config.ExcludeSubmodules) > 0 {
+		if submodules = excludeSubmodules(submodules, rnw.config.ExcludeSubmodules); len(submodules) == 0 {
+			return nil, nil
+		}
+	}
+	if depth == 0 && rnw.config.SubmoduleFilter != "" {
+		if submodules = filterSubmodules(submodules, rnw.config.SubmoduleFilter); len(submodules) == 0 {
+			return nil, nil
+		}
+	}
+
+	// the submodules are fetched concurrently by up to Concurrency workers, and stored by index
+	// to keep the order of the .gitmodules file
+	sections := make([]*SubmoduleChanges, len(submodules))
+	workers := make(chan struct{}, max(rnw.config.Concurrency, 1))
+	var wg sync.WaitGroup
+	for i, sm := range submodules {
+		workers <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sections[i] = rnw.submoduleSection(ctx, owner, repo, commit, prevCommit, sm, depth, ancestors)
+			<-workers
+		}()
+	}
+	wg.Wait()
+	var result []*SubmoduleChanges
+	for _, section := range sections {
+		if section != nil {
+			result = append(result, section)
+		}
+	}
+	return result, nil
+}
+
+// sortSubmodulesByChanges sorts the submodule changes, and their nested submodule changes, by
+// their number of changes in descending order. Submodules with the same number of changes keep
+// their relative order
+func sortSubmodulesByChanges(smChanges []*SubmoduleChanges) {
+	slices.SortStableFunc(smChanges, func(a, b *SubmoduleChanges) int {
+		return len(b.Changes) - len(a.Changes)
+	})
+	for _, sm := range smChanges {
+		sortSubmodulesByChanges(sm.Submodules)
+	}
+}
+
+// excludeSubmodules returns the submodules whose name or path is not in the excluded list
+func excludeSubmodules(submodules []gitSubmodule, excluded []string) []gitSubmodule {
+	skip := map[string]struct{}{}
+	for _, e := range excluded {
+		skip[strings.Trim(e, "/")] = struct{}{}
+	}
+	var result []gitSubmodule
+	for _, sm := range submodules {
+		_, byName := skip[sm.Name]
+		_, byPath := skip[sm.Path]
+		if byName || byPath {
+			slog.Debug("submodule excluded", "name", sm.Name, "path", sm.Path)
+			continue
+		}
+		result = append(result, sm)
+	}
+	return result
+}
+
+// filterSubmodules returns the submodule whose name or path is the given filter. If none
+// matches, it warns about the available submodule names
+func filterSubmodules(submodules []gitSubmodule, filter string) []gitSubmodule {
+	filter = strings.Trim(filter, "/")
+	for _, sm := range submodules {
+		if sm.Name == filter || sm.Path == filter {
+			return []gitSubmodule{sm}
+		}
+	}
+	names := make([]string, 0, len(submodules))
+	for _, sm := range submodules {
+		names = append(names, sm.Name)
+	}
+	slog.Warn("no submodule matches submodule_filter", "filter", filter,
+		"available", strings.Join(names, ", "))
+	return nil
+}
+
+// submoduleSection returns the changes of the submodule and its nested submodules, or nil if the
+// submodule has not been updated. A failing submodule is reported in its own section through
+// the Err field, without aborting the rest
+func (rnw *ReleaseNotesWriter) submoduleSection(
+	ctx context.Context, owner, repo, commit, prevCommit string, sm gitSubmodule, depth int, ancestors []string,
+) *SubmoduleChanges {
+	smChanges, err := rnw.getChangesForSubmodule(ctx, owner, repo, commit, prevCommit, sm)
+	if err != nil && sm.onGitHub() && (isNotFound(err) || isForbidden(err)) {
+		// private repositories are reported as not found when the token can't read them
+		err = fmt.Errorf("token cannot access %s (or the compared commits don't exist); add it to the token's "+
+			"scope or set the submodule_github_token input: %w", sm.Repo, err)
+	}
+	if err != nil {
+		slog.Warn("can't resolve submodule changes", "path", sm.Path, "repository", sm.Repo, "error", err)
+		return &SubmoduleChanges{Name: sm.Name, Host: sm.Host, Repo: sm.Repo, Path: sm.Path, Depth: depth, Err: err}
+	}
+	if smChanges.State == SubmoduleUpdated && smChanges.Old == smChanges.New {
+		slog.Debug("submodule not updated", "path", sm.Path, "commit", smChanges.New)
+		return nil
+	}
+	smChanges.Depth = depth
+	if err := rnw.recurseSubmodule(ctx, smChanges, sm, depth, ancestors); err != nil {
+		slog.Warn("can't resolve nested submodule changes", "path", sm.Path, "repository", sm.Repo, "error", err)
+		smChanges.Err = err
+	}
+	return smChanges
+}
+
+// recurseSubmodule sets the changes of the nested submodules of an updated submodule, as long as
+// the RecursiveDepth is not exceeded. Only GitHub-hosted submodules can be traversed
+func (rnw *ReleaseNotesWriter) recurseSubmodule(
+	ctx context.Context, smChanges *SubmoduleChanges, submodule gitSubmodule, depth int, ancestors []string,
+) error {
+	if depth >= rnw.config.RecursiveDepth || smChanges.State != SubmoduleUpdated {
+		return nil
+	}
+	if submodule.Host == gitlabHost {
+		slog.Debug("can't look for nested submodules out of GitHub", "path", submodule.Path, "host", submodule.Host)
+		return nil
+	}
+	if slices.ContainsFunc(ancestors, func(a string) bool { return strings.EqualFold(a, submodule.Repo) }) {
+		slog.Warn("submodule cycle detected. Ignoring nested submodules", "path", submodule.Path, "repository", submodule.Repo)
+		return nil
+	}
+	smOwner, smRepo, _ := strings.Cut(submodule.Repo, "/")
+	// the nested .gitmodules and trees are read from the submodule repository
+	nested, err := rnw.forSubmodules().getNestedChangesForSubmodules(ctx, smOwner, smRepo, smChanges.New, smChanges.Old,
+		depth+1, append(ancestors[:len(ancestors):len(ancestors)], submodule.Repo))
+	if err != nil {
+		return fmt.Errorf("nested submodules: %w", err)
+	}
+	smChanges.Submodules = nested
+	return nil
+}
+
+func (rnw *ReleaseNotesWriter) getChangesForSubmodule(
+	ctx context.Context, owner string, repo string, commit string, prevCommit string, submodule gitSubmodule,
+) (*SubmoduleChanges, error) {
+	slog.Debug("submodule found", "path", submodule.Path, "repository", submodule.Repo, "host", submodule.Host)
+	src, err := rnw.sourceFor(submodule)
+	if err != nil {
+		return nil, err
+	}
+
+	// get the changes for the submodule commits
+	smCommits, err := rnw.getSubmoduleCommits(ctx, owner, repo, prevCommit, commit, submodule, src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get submodule commits: %w", err)
+	}
+	result := &SubmoduleChanges{
+		Name:         submodule.Name,
+		Host:         submodule.Host,
+		Repo:         submodule.Repo,
+		PreviousHost: submodule.PreviousHost,
+		PreviousRepo: submodule.PreviousRepo,
+		Path:         submodule.Path,
+		State:        smCommits.State,
+		Old:          smCommits.Old,
+		New:          smCommits.New,
+	}
+	if smCommits.State != SubmoduleRemoved {
+		// the tags are only informative, so the notes are still generated if they can't be listed
+		if tags, err := src.tagsByCommit(ctx); err != nil {
+			slog.Warn("can't resolve submodule tags", "repository", submodule.Repo, "error", err)
+		} else {
+			result.OldTag, result.NewTag = tags[smCommits.Old], tags[smCommits.New]
+		}
+	}
+	slog.Info("comparing submodule commits", "repository", submodule.Repo,
+		"commit", result.newRef(), "previous", result.oldRef())
+
+	switch smCommits.State {
+	case SubmoduleAdded:
+		result.Changes, err = src.changesUpTo(ctx, smCommits.New)
+	case SubmoduleUpdated:
+		result.Changes, err = src.changes(ctx, smCommits.New, smCommits.Old)
+		// the previous commit may have been force-pushed away or belong to a deleted branch
+		if gh, ok := src.(githubSource); ok && (isNotFound(err) || isUnprocessable(err)) {
+			slog.Warn("can't compare submodule commits. Listing the commits reachable from the current one",
+				"repository", submodule.Repo, "commit", result.newRef(), "previous", result.oldRef(), "error", err)
+			result.Uncompared = true
+			result.Changes, err = gh.rnw.getChangesSince(ctx, gh.owner, gh.repo, smCommits.New, smCommits.Old)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get submodule changes: %w", err)
+	}
+	// the GitHub changes are already filtered as they are fetched, like those of the main
+	// repository, so filtering them again could match the patterns against the linked subjects
+	if _, ok := src.(githubSource); !ok {
+		result.Changes = rnw.filterChanges(result.Changes)
+		// the kept suffixes are linked to the submodule host by replaceSubmoduleLinks, instead
+		// of to a GitHub pull request
+		if rnw.config.PRSuffix == PRSuffixStrip {
+			rnw.handlePRSuffix(result.Changes)
+		}
+	}
+	if rnw.config.SubmodulePathFilter != "" {
+		result.Changes, err = filterByPath(ctx, result.Changes, src.commitFiles, underPath(rnw.config.SubmodulePathFilter))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if gh, ok := src.(githubSource); ok && rnw.config.GroupByLabel {
+		if err := gh.rnw.resolvePullRequests(ctx, gh.owner, gh.repo, result.Changes); err != nil {
+			return nil, err
+		}
+	}
+	if len(rnw.groupRules) > 0 {
+		result.Changes = categorizeByRules(rnw.groupRules, result.Changes)
+	}
+	// In submodule, replaces #PR_NUMBER by repo/name#PR_NUMBER for proper linking from GitHub
+	format := rnw.submoduleReference(submodule)
+	if gh, ok := src.(githubSource); ok && rnw.config.ResolveReferences {
+		// the titles are appended after the references are rewritten, so the #123 inside them
+		// aren't rewritten
+		if err := gh.rnw.resolveReferences(ctx, gh.owner, gh.repo, result.Changes, format); err != nil {
+			return nil, err
+		}
+	} else {
+		replaceSubmoduleLinks(result.Changes, format)
+	}
+	// the GitLab comparisons don't report the inserted and deleted lines
+	if gh, ok := src.(githubSource); ok && rnw.config.ShowDiffstat && smCommits.State == SubmoduleUpdated {
+		// the diffstat is only informative, so the notes are still generated without it
+		if result.DiffStat, err = gh.rnw.diffStat(ctx, gh.owner, gh.repo, smCommits.New, smCommits.Old); err != nil {
+			slog.Warn("can't resolve the submodule diffstat", "repository", submodule.Repo, "error", err)
+		}
+	}
+	return result, nil
+}
+
+// repoSource provides the changes of a submodule repository, regardless of where it is hosted
+type repoSource interface {
+	// changes returns the changes for the commits that are reachable from commit but not from prevCommit
+	changes(ctx context.Context, commit, prevCommit string) ([]Change, error)
+	// changesUpTo returns the changes for all the commits that are reachable from commit
+	changesUpTo(ctx context.Context, commit string) ([]Change, error)
+	// branchHead returns the last commit of the given branch
+	branchHead(ctx context.Context, branch string) (string, error)
+	// tagsByCommit returns the tag names indexed by the commit they point to
+	tagsByCommit(ctx context.Context) (map[string]string, error)
+	// commitFiles returns the paths of the files modified by the commit
+	commitFiles(ctx context.Context, sha string) ([]string, error)
+}
+
+// githubSource provides the changes of a submodule hosted in GitHub
+type githubSource struct {
+	rnw   *ReleaseNotesWriter
+	owner string
+	repo  string
+}
+
+func (s githubSource) changes(ctx context.Context, commit, prevCommit string) ([]Change, error) {
+	return s.rnw.getChanges(ctx, s.owner, s.repo, commit, prevCommit)
+}
+
+func (s githubSource) changesUpTo(ctx context.Context, commit string) ([]Change, error) {
+	return s.rnw.getChangesUpTo(ctx, s.owner, s.repo, commit)
+}
+
+func (s githubSource) branchHead(ctx context.Context, branch string) (string, error) {
+	return s.rnw.commitForRef(ctx, s.owner, s.repo, "heads/"+branch)
+}
+
+func (s githubSource) commitFiles(ctx context.Context, sha string) ([]string, error) {
+	return s.rnw.commitFiles(ctx, s.owner, s.repo, sha)
+}
+
+func (s githubSource) tagsByCommit(ctx context.Context) (map[string]string, error) {
+	return s.rnw.tagsByCommit(ctx, s.owner, s.repo)
+}
+
+// sourceFor returns the repoSource for the host of the submodule URL (see gitSubmodule.onGitHub)
+func (rnw *ReleaseNotesWriter) sourceFor(submodule gitSubmodule) (repoSource, error) {
+	if !submodule.onGitHub() {
+		return gitlabSource{client: rnw.gitlab, project: submodule.Repo, subjectMode: rnw.config.SubjectMode}, nil
+	}
+	parts := strings.Split(submodule.Repo, "/")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid submodule repository format: %s (expected owner/repo)", submodule.Repo)
+	}
+	return githubSource{rnw: rnw.forSubmodules(), owner: parts[0], repo: parts[1]}, nil
+}
+
+// getSubmoduleCommits returns the commits that the submodule points to in the old and new commits
+// of the main repository. If the submodule tracks a branch and the new tree does not pin any commit
+// for it (e.g. the gitlink is missing or the tree listing is truncated), the head of the tracked
+// branch in the submodule repository is taken as the new commit.
+func (rnw *ReleaseNotesWriter) getSubmoduleCommits(
+	ctx context.Context, owner, repo, oldCommit, newCommit string,
+	submodule gitSubmodule, src repoSource,
+) (submoduleCommits, error) {
+	gitlink := rnw.getGitlink
+	if submodule.Pin != "" {
+		gitlink = rnw.getLockfilePin
+	}
+	// Get submodule commit at old tag
+	oldSubmoduleCommit, err := gitlink(ctx, owner, repo, oldCommit, submodule.Path)
+	if err != nil {
+		return submoduleCommits{}, fmt.Errorf("failed to get old tree: %w", err)
+	}
+
+	// Get submodule commit at new tag
+	newSubmoduleCommit, err := gitlink(ctx, owner, repo, newCommit, submodule.Path)
+	if err != nil {
+		return submoduleCommits{}, fmt.Errorf("failed to get new tree: %w", err)
+	}
+	if newSubmoduleCommit == "" {
+		if branch := rnw.trackedBranch(submodule); branch != "" {
+			newSubmoduleCommit, err = src.branchHead(ctx, branch)
+			if err != nil {
+				return submoduleCommits{}, fmt.Errorf("failed to get head of tracked branch %s: %w", branch, err)
+			}
+			slog.Info("submodule is not pinned. Using head of tracked branch", "path", submodule.Path, "branch", branch)
+		}
+	}
+
+	sc := submoduleCommits{Old: oldSubmoduleCommit, New: newSubmoduleCommit}
+	switch {
+	case oldSubmoduleCommit == "" && newSubmoduleCommit == "":
+		return submoduleCommits{}, fmt.Errorf("submodule not found in any of the tags")
+	case oldSubmoduleCommit == "":
+		sc.State = SubmoduleAdded
+	case newSubmoduleCommit == "":
+		sc.State = SubmoduleRemoved
+	default:
+		sc.State = SubmoduleUpdated
+	}
+	return sc, nil
+}
+
+// getGitlink returns the commit that the submodule at the given path points to, or an empty string
+// if the tree of the provided commit does not contain such submodule
+func (rnw *ReleaseNotesWriter) getGitlink(ctx context.Context, owner, repo, commit, submodulePath string) (string, error) {
+	tree, _, err := rnw.client.Git.GetTree(ctx, owner, repo, commit, true)
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range tree.Entries {
+		if entry.GetPath() == submodulePath && entry.GetType() == "commit" {
+			return entry.GetSHA(), nil
+		}
+	}
+	if tree.GetTruncated() {
+		slog.Warn("tree is truncated. Submodule might not be found", "commit", commit, "path", submodulePath)
+	}
+	return "", nil
+}
+
+// trackedBranch returns the branch of the submodule repository that is tracked according to the
+// .gitmodules file, or an empty string if the submodule does not track any branch.
+// The special "." value means that the submodule tracks the branch with the same name as the
+// current branch in the main repository, which is only known when the HeadBranch is provided.
+func (rnw *ReleaseNotesWriter) trackedBranch(submodule gitSubmodule) string {
+	if submodule.Branch == "." {
+		return rnw.config.HeadBranch
+	}
+	return submodule.Branch
+}
+
+// getSubmodulePathRepo returns the submodules declared in the .gitmodules file at the given commit,
+// whose URL can be resolved to an owner/repo name. The result is memoized for the rest of the run
+func (rnw *ReleaseNotesWriter) getSubmodulePathRepo(ctx context.Context, owner, repo, commit string) ([]gitSubmodule, error) {
+	if submodules, ok := rnw.submodules.get(owner, repo, commit); ok {
+		return submodules, nil
+	}
+	submodules, err := rnw.fetchSubmodulePathRepo(ctx, owner, repo, commit)
+	if err == nil {
+		rnw.submodules.put(owner, repo, commit, submodules)
+	}
+	return submodules, err
+}
+
+func (rnw *ReleaseNotesWriter) fetchSubmodulePathRepo(ctx context.Context, owner, repo, commit string) ([]gitSubmodule, error) {
+	// Get the .gitmodules file content from the repository at a specific commit
+	gitmodulesContent, dirContent, _, err := rnw.client.Repositories.GetContents(ctx, owner, repo, ".gitmodules", &github.RepositoryContentGetOptions{
+		Ref: commit, // or tag, branch name
+	})
+	if isNotFound(err) {
+		// the repository does not have submodules
+		slog.Info("no .gitmodules file found. Assuming no submodules", "repository", owner+"/"+repo, "commit", commit)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .gitmodules from repository: %w", err)
+	}
+	if gitmodulesContent == nil {
+		// the path exists but it isn't a file, so its (empty) content must not be taken as no submodules
+		return nil, fmt.Errorf(".gitmodules in %s/%s at %s is not a file (found a directory with %d entries)",
+			owner, repo, commit, len(dirContent))
+	}
+
+	// Decode the content (GitHub API returns base64-encoded content)
+	content, err := gitmodulesContent.GetContent()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode .gitmodules content: %w", err)
+	}
+
+	var submodules []gitSubmodule
+	for _, sm := range parseGitmodules(content) {
+		if sm.Path == "" {
+			continue
+		}
+		if sm.Repo == "" {
+			slog.Warn("can't resolve submodule repository. Ignoring it", "path", sm.Path, "url", sm.URL, "error", sm.URLErr)
+			continue
+		}
+		submodules = append(submodules, sm)
+	}
+	return submodules, nil
+}
+
+// matches the bare #PR_NUMBER references, which are not already qualified with a repository
+// (like owner/repo#123) nor preceded by a word character. Letters and digits of any script are
+// word characters, so the matched runes are always whole UTF-8 characters
+var bareReference = regexp.MustCompile(`(^|[^\pL\pN_./-])#(\d+)`)
+
+// referenceFormat returns the text that replaces the bare #number reference
+type referenceFormat func(number string) string
+
+// githubReference formats the references as GitHub cross-repository references (owner/repo#123)
+func githubReference(prefix string) referenceFormat {
+	return func(number string) string {
+		return prefix + "#" + number
+	}
+}
+
+// gitlabReference formats the references as links to the issues of a GitLab project, since the
+// notes are published in GitHub, where group/project#123 would link to a GitHub repository
+func gitlabReference(project string) referenceFormat {
+	return func(number string) string {
+		return "[" + project + "#" + number + "](https://" + gitlabHost + "/" + project + "/-/issues/" + number + ")"
+	}
+}
+
+// submoduleReference returns the format of the bare references of the submodule changes,
+// according to the GeneratedSubmoduleLink and SubmoduleLinks options and the submodule host
+func (rnw *ReleaseNotesWriter) submoduleReference(submodule gitSubmodule) referenceFormat {
+	if rnw.config.GeneratedSubmoduleLink != "" {
+		return githubReference(rnw.config.GeneratedSubmoduleLink)
+	}
+	if submodule.Host == gitlabHost && rnw.config.SubmoduleLinks != SubmoduleLinksGitHub {
+		return gitlabReference(submodule.Repo)
+	}
+	return githubReference(submodule.Repo)
+}
+
+// replaceSubmoduleLinks rewrites the bare #PR_NUMBER references of the submodule changes with
+// the provided format, so they link to the submodule repository instead of the main one
+func replaceSubmoduleLinks(entries []Change, format referenceFormat) {
+	for i := range entries {
+		entries[i].Subject = rewriteBareReferences(entries[i].Subject, format)
+	}
+}
+
+// rewriteBareReferences replaces each bare reference of the text that is not followed by a word
+// character (e.g. #10a is not a reference) with its formatted version. The rest of the text is
+// copied verbatim
+func rewriteBareReferences(text string, format referenceFormat) string {
+	var sb strings.Builder
+	last := 0
+	for _, m := range bareReference.FindAllStringSubmatchIndex(text, -1) {
+		// m[3] is the end of the character before the #, and m[4]:m[5] the number
+		if next, _ := utf8.DecodeRuneInString(text[m[5]:]); m[5] < len(text) && isWordRune(next) {
+			continue
+		}
+		sb.WriteString(text[last:m[3]])
+		sb.WriteString(format(text[m[4]:m[5]]))
+		last = m[5]
+	}
+	sb.WriteString(text[last:])
+	return sb.String()
+}
+
+// isWordRune returns whether the rune is a letter or digit of any script, or an underscore
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
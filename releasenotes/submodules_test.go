@@ -0,0 +1,700 @@
+package releasenotes
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v57/github"
+)
+
+// gitmodulesHandler serves the provided .gitmodules contents from the GitHub contents API
+func gitmodulesHandler(content string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(w, `{"type": "file", "encoding": "base64", "content": %q}`,
+			base64.StdEncoding.EncodeToString([]byte(content)))
+	}
+}
+
+func TestGetChangesForSubmodules_LinksPerSubmodule(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/contents/.gitmodules", gitmodulesHandler(`
+[submodule "first"]
+	path = first
+	url = https://github.com/org1/first.git
+[submodule "second"]
+	path = second
+	url = git@github.com:org2/second.git
+`))
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, r *http.Request) {
+		sha := r.PathValue("sha")
+		fmt.Fprintf(w, `{"tree": [
+			{"path": "first", "type": "commit", "sha": "first-%[1]s"},
+			{"path": "second", "type": "commit", "sha": "second-%[1]s"}
+		]}`, sha)
+	})
+	mux.HandleFunc("GET /repos/org1/first/compare/first-old...first-new", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"commits": [{"sha": "f1", "commit": {"message": "Fix first (#1)"}}]}`)
+	})
+	mux.HandleFunc("GET /repos/org2/second/compare/second-old...second-new", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"commits": [{"sha": "s1", "commit": {"message": "Fix second (#1)"}}]}`)
+	})
+	rnw := newTestWriter(t, Options{}, mux)
+
+	smChanges, err := rnw.getChangesForSubmodules(t.Context(), "owner", "repo", "new", "old")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var subjects []string
+	for _, sm := range smChanges {
+		for _, c := range sm.Changes {
+			subjects = append(subjects, c.Subject)
+		}
+	}
+	want := []string{"Fix first (org1/first#1)", "Fix second (org2/second#1)"}
+	if !reflect.DeepEqual(subjects, want) {
+		t.Errorf("subjects = %q, want %q", subjects, want)
+	}
+}
+
+func TestGetChangesForSubmodules_MovedRepository(t *testing.T) {
+	mux := http.NewServeMux()
+	gitmodules := map[string]http.HandlerFunc{
+		"old": gitmodulesHandler("[submodule \"lib\"]\n\tpath = lib\n\turl = https://github.com/oldorg/lib.git\n"),
+		"new": gitmodulesHandler("[submodule \"lib\"]\n\tpath = lib\n\turl = https://github.com/neworg/lib.git\n"),
+	}
+	mux.HandleFunc("GET /repos/owner/repo/contents/.gitmodules", func(w http.ResponseWriter, r *http.Request) {
+		gitmodules[r.URL.Query().Get("ref")](w, r)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tree": [{"path": "lib", "type": "commit", "sha": "lib-%s"}]}`, r.PathValue("sha"))
+	})
+	// the commits are compared in the current repository
+	mux.HandleFunc("GET /repos/neworg/lib/compare/lib-old...lib-new", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"commits": [{"sha": "l1", "commit": {"message": "Fix after the move"}}]}`)
+	})
+	rnw := newTestWriter(t, Options{}, mux)
+
+	smChanges, err := rnw.getChangesForSubmodules(t.Context(), "owner", "repo", "new", "old")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(smChanges) != 1 {
+		t.Fatalf("got %d submodules, want 1: %+v", len(smChanges), smChanges)
+	}
+	sm := smChanges[0]
+	if sm.Repo != "neworg/lib" || sm.PreviousRepo != "oldorg/lib" || sm.State != SubmoduleUpdated || len(sm.Changes) != 1 {
+		t.Errorf("unexpected submodule changes: %+v", sm)
+	}
+	want := "\n## Changes from neworg/lib:\n> Submodule lib moved from oldorg/lib to neworg/lib\n\n* Fix after the move\n"
+	if got := sm.render(Options{}); got != want {
+		t.Errorf("render() = %q, want %q", got, want)
+	}
+}
+
+func TestGetChangesForSubmodules_Recursive(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/contents/.gitmodules", gitmodulesHandler(`
+[submodule "lib"]
+	path = lib
+	url = https://github.com/org1/lib.git
+`))
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tree": [{"path": "lib", "type": "commit", "sha": "lib-%s"}]}`, r.PathValue("sha"))
+	})
+	// the lib submodule contains an inner submodule and a submodule pointing back to the main repository
+	mux.HandleFunc("GET /repos/org1/lib/contents/.gitmodules", gitmodulesHandler(`
+[submodule "inner"]
+	path = inner
+	url = https://github.com/org1/inner.git
+[submodule "parent"]
+	path = parent
+	url = https://github.com/owner/repo.git
+`))
+	mux.HandleFunc("GET /repos/org1/lib/git/trees/{sha}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tree": [
+			{"path": "inner", "type": "commit", "sha": "inner-%[1]s"},
+			{"path": "parent", "type": "commit", "sha": "parent-%[1]s"}
+		]}`, r.PathValue("sha"))
+	})
+	mux.HandleFunc("GET /repos/org1/lib/compare/lib-old...lib-new", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"commits": [{"sha": "l1", "commit": {"message": "Bump inner"}}]}`)
+	})
+	mux.HandleFunc("GET /repos/org1/inner/compare/inner-lib-old...inner-lib-new", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"commits": [{"sha": "i1", "commit": {"message": "Fix inner"}}]}`)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/compare/parent-lib-old...parent-lib-new", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"commits": [{"sha": "p1", "commit": {"message": "Fix parent"}}]}`)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/contents/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("the submodule cycle must not be followed: %s", r.URL)
+		http.NotFound(w, r)
+	})
+	rnw := newTestWriter(t, Options{Repository: "owner/repo", RecursiveDepth: 5}, mux)
+
+	smChanges, err := rnw.getChangesForSubmodules(t.Context(), "owner", "repo", "new", "old")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(smChanges) != 1 || len(smChanges[0].Submodules) != 2 {
+		t.Fatalf("expected one submodule with two nested submodules, got %+v", smChanges)
+	}
+	notes := renderMarkdown(rnw.config, ReleaseNotes{Submodules: smChanges})
+	// the main section is omitted, as it has no changes
+	want := "## Changes from org1/lib:\n* Bump inner\n" +
+		"\n### Changes from org1/inner:\n* Fix inner\n" +
+		"\n### Changes from owner/repo:\n* Fix parent\n"
+	if notes != want {
+		t.Errorf("renderMarkdown() = %q, want %q", notes, want)
+	}
+}
+
+func TestGetChangesForSubmodules_RecursiveDepthDisabled(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/contents/.gitmodules", gitmodulesHandler(`
+[submodule "lib"]
+	path = lib
+	url = https://github.com/org1/lib.git
+`))
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tree": [{"path": "lib", "type": "commit", "sha": "lib-%s"}]}`, r.PathValue("sha"))
+	})
+	mux.HandleFunc("GET /repos/org1/lib/compare/lib-old...lib-new", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"commits": [{"sha": "l1", "commit": {"message": "Bump inner"}}]}`)
+	})
+	mux.HandleFunc("GET /repos/org1/lib/contents/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("nested submodules must not be looked up: %s", r.URL)
+		http.NotFound(w, r)
+	})
+	rnw := newTestWriter(t, Options{}, mux)
+
+	smChanges, err := rnw.getChangesForSubmodules(t.Context(), "owner", "repo", "new", "old")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(smChanges) != 1 || smChanges[0].Submodules != nil {
+		t.Errorf("unexpected submodule changes: %+v", smChanges)
+	}
+}
+
+func TestGetChangesForSubmodules_SubmoduleFilter(t *testing.T) {
+	for _, filter := range []string{"second-name", "libs/second", "unknown"} {
+		t.Run(filter, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /repos/owner/repo/contents/.gitmodules", gitmodulesHandler(`
+[submodule "first"]
+	path = first
+	url = https://github.com/org1/first.git
+[submodule "second-name"]
+	path = libs/second
+	url = https://github.com/org2/second.git
+`))
+			mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, `{"tree": [
+					{"path": "first", "type": "commit", "sha": "first-%[1]s"},
+					{"path": "libs/second", "type": "commit", "sha": "second-%[1]s"}
+				]}`, r.PathValue("sha"))
+			})
+			// the first submodule would fail if it was processed
+			mux.HandleFunc("GET /repos/org2/second/compare/second-old...second-new", func(w http.ResponseWriter, _ *http.Request) {
+				fmt.Fprint(w, `{"commits": [{"sha": "s1", "commit": {"message": "Fix second"}}]}`)
+			})
+			rnw := newTestWriter(t, Options{SubmoduleFilter: filter}, mux)
+
+			smChanges, err := rnw.getChangesForSubmodules(t.Context(), "owner", "repo", "new", "old")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if filter == "unknown" {
+				if len(smChanges) != 0 {
+					t.Errorf("expected no submodule changes, got %+v", smChanges)
+				}
+				return
+			}
+			if len(smChanges) != 1 || smChanges[0].Name != "second-name" || smChanges[0].Err != nil ||
+				len(smChanges[0].Changes) != 1 {
+				t.Errorf("expected only the changes of the second submodule, got %+v", smChanges)
+			}
+		})
+	}
+}
+
+func TestGetChangesForSubmodules_ExcludeSubmodules(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/contents/.gitmodules", gitmodulesHandler(`
+[submodule "fixtures"]
+	path = test/fixtures
+	url = https://github.com/org1/fixtures.git
+[submodule "second-name"]
+	path = libs/second
+	url = https://github.com/org2/second.git
+[submodule "third"]
+	path = third
+	url = https://github.com/org3/third.git
+`))
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tree": [
+			{"path": "test/fixtures", "type": "commit", "sha": "fixtures-%[1]s"},
+			{"path": "libs/second", "type": "commit", "sha": "second-%[1]s"},
+			{"path": "third", "type": "commit", "sha": "third-%[1]s"}
+		]}`, r.PathValue("sha"))
+	})
+	// the excluded submodules would fail if they were processed
+	mux.HandleFunc("GET /repos/org2/second/compare/second-old...second-new", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"commits": [{"sha": "s1", "commit": {"message": "Fix second"}}]}`)
+	})
+	rnw := newTestWriter(t, Options{ExcludeSubmodules: []string{"test/fixtures/", "third"}}, mux)
+
+	smChanges, err := rnw.getChangesForSubmodules(t.Context(), "owner", "repo", "new", "old")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(smChanges) != 1 || smChanges[0].Name != "second-name" || smChanges[0].Err != nil {
+		t.Errorf("expected only the changes of the second submodule, got %+v", smChanges)
+	}
+}
+
+func TestGetChangesForSubmodules_SkipsUnchanged(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/contents/.gitmodules", gitmodulesHandler(`
+[submodule "lib"]
+	path = lib
+	url = https://github.com/org1/lib.git
+`))
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"tree": [{"path": "lib", "type": "commit", "sha": "same"}]}`)
+	})
+	rnw := newTestWriter(t, Options{}, mux)
+
+	smChanges, err := rnw.getChangesForSubmodules(t.Context(), "owner", "repo", "new", "old")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(smChanges) != 0 {
+		t.Errorf("expected the unchanged submodule to be skipped, got %+v", smChanges)
+	}
+}
+
+func TestGetChangesForSubmodule_States(t *testing.T) {
+	trees := map[string]string{
+		"with":    `{"tree": [{"path": "lib", "type": "commit", "sha": "aaa"}]}`,
+		"bumped":  `{"tree": [{"path": "lib", "type": "commit", "sha": "bbb"}]}`,
+		"without": `{"tree": [{"path": "README.md", "type": "blob", "sha": "readme"}]}`,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, trees[r.PathValue("sha")])
+	})
+	mux.HandleFunc("GET /repos/org1/lib/compare/aaa...bbb", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"status": "ahead", "commits": [{"sha": "c2", "commit": {"message": "Fix crash (#7)"}}]}`)
+	})
+	mux.HandleFunc("GET /repos/org1/lib/commits", func(w http.ResponseWriter, r *http.Request) {
+		if sha := r.URL.Query().Get("sha"); sha != "aaa" {
+			t.Errorf("unexpected listing of the commits up to %q", sha)
+		}
+		fmt.Fprint(w, `[{"sha": "c1", "commit": {"message": "Initial import"}}]`)
+	})
+	rnw := newTestWriter(t, Options{PRSuffix: PRSuffixKeep, SubjectMode: SubjectFirstLine}, mux)
+	submodule := gitSubmodule{Name: "lib", Path: "lib", Host: "github.com", Repo: "org1/lib"}
+
+	tests := []struct {
+		name     string
+		old, new string
+		want     SubmoduleChanges
+	}{
+		{name: "added", old: "without", new: "with", want: SubmoduleChanges{
+			State: SubmoduleAdded, New: "aaa", Changes: []Change{{SHA: "c1", Subject: "Initial import"}},
+		}},
+		{name: "updated", old: "with", new: "bumped", want: SubmoduleChanges{
+			State: SubmoduleUpdated, Old: "aaa", New: "bbb", Changes: []Change{{SHA: "c2", Subject: "Fix crash (org1/lib#7)"}},
+		}},
+		{name: "removed", old: "with", new: "without", want: SubmoduleChanges{
+			State: SubmoduleRemoved, Old: "aaa",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := rnw.getChangesForSubmodule(t.Context(), "owner", "repo", tt.new, tt.old, submodule)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if sc.Repo != "org1/lib" || sc.Path != "lib" || sc.State != tt.want.State ||
+				sc.Old != tt.want.Old || sc.New != tt.want.New {
+				t.Errorf("unexpected submodule changes: %+v", sc)
+			}
+			var got []Change
+			for _, c := range sc.Changes {
+				got = append(got, Change{SHA: c.SHA, Subject: c.Subject})
+			}
+			if !reflect.DeepEqual(got, tt.want.Changes) {
+				t.Errorf("changes = %+v, want %+v", got, tt.want.Changes)
+			}
+		})
+	}
+}
+
+func TestGetChangesForSubmodules_ContinuesOnFailure(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/contents/.gitmodules", gitmodulesHandler(`
+[submodule "broken"]
+	path = broken
+	url = https://github.com/org1/broken.git
+[submodule "second"]
+	path = second
+	url = https://github.com/org2/second.git
+`))
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tree": [
+			{"path": "broken", "type": "commit", "sha": "broken-%[1]s"},
+			{"path": "second", "type": "commit", "sha": "second-%[1]s"}
+		]}`, r.PathValue("sha"))
+	})
+	mux.HandleFunc("GET /repos/org1/broken/compare/{basehead}", func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, `{"message": "Server Error"}`, http.StatusInternalServerError)
+	})
+	mux.HandleFunc("GET /repos/org2/second/compare/second-old...second-new", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"commits": [{"sha": "s1", "commit": {"message": "Fix second"}}]}`)
+	})
+	rnw := newTestWriter(t, Options{Repository: "owner/repo"}, mux)
+
+	smChanges, err := rnw.getChangesForSubmodules(t.Context(), "owner", "repo", "new", "old")
+	if err != nil {
+		t.Fatalf("a failing submodule must not abort the others: %v", err)
+	}
+	if len(smChanges) != 2 || smChanges[0].Err == nil || smChanges[1].Err != nil {
+		t.Fatalf("expected only the first submodule to fail, got %+v", smChanges)
+	}
+	notes := smChanges[0].render(rnw.config) + smChanges[1].render(rnw.config)
+	if !strings.Contains(notes, "> ⚠️ could not resolve changes for org1/broken: ") {
+		t.Errorf("expected warning note in the failing section: %q", notes)
+	}
+	if !strings.Contains(notes, "## Changes from org2/second:\n* Fix second\n") {
+		t.Errorf("expected notes of the other submodule: %q", notes)
+	}
+}
+
+func TestGetChangesForSubmodules_InaccessibleRepository(t *testing.T) {
+	for _, status := range []int{http.StatusNotFound, http.StatusForbidden} {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /repos/owner/repo/contents/.gitmodules", gitmodulesHandler(`
+[submodule "private"]
+	path = private
+	url = https://github.com/org1/private.git
+[submodule "second"]
+	path = second
+	url = https://github.com/org2/second.git
+`))
+		mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"tree": [
+				{"path": "private", "type": "commit", "sha": "private-%[1]s"},
+				{"path": "second", "type": "commit", "sha": "second-%[1]s"}
+			]}`, r.PathValue("sha"))
+		})
+		mux.HandleFunc("GET /repos/org1/private/{path...}", func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, `{"message": "Not Found"}`, status)
+		})
+		mux.HandleFunc("GET /repos/org2/second/compare/second-old...second-new", func(w http.ResponseWriter, _ *http.Request) {
+			fmt.Fprint(w, `{"commits": [{"sha": "s1", "commit": {"message": "Fix second"}}]}`)
+		})
+		rnw := newTestWriter(t, Options{}, mux)
+
+		smChanges, err := rnw.getChangesForSubmodules(t.Context(), "owner", "repo", "new", "old")
+		if err != nil {
+			t.Fatalf("an inaccessible submodule must not abort the others: %v", err)
+		}
+		if len(smChanges) != 2 || smChanges[0].Err == nil || smChanges[1].Err != nil {
+			t.Fatalf("expected only the first submodule to fail, got %+v", smChanges)
+		}
+		if msg := smChanges[0].Err.Error(); !strings.Contains(msg, "token cannot access org1/private") ||
+			!strings.Contains(msg, "submodule_github_token") {
+			t.Errorf("expected an actionable error for status %d, got %q", status, msg)
+		}
+	}
+}
+
+func TestGetChangesForSubmodules_UnreachablePreviousCommit(t *testing.T) {
+	for _, status := range []int{http.StatusNotFound, http.StatusUnprocessableEntity} {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /repos/owner/repo/contents/.gitmodules", gitmodulesHandler(`
+[submodule "lib"]
+	path = lib
+	url = https://github.com/other/lib.git
+`))
+		mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"tree": [{"path": "lib", "type": "commit", "sha": "lib-%s"}]}`, r.PathValue("sha"))
+		})
+		mux.HandleFunc("GET /repos/other/lib/compare/lib-old...lib-new", func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, `{"message": "No common ancestor"}`, status)
+		})
+		mux.HandleFunc("GET /repos/other/lib/commits/lib-old", func(w http.ResponseWriter, _ *http.Request) {
+			fmt.Fprint(w, `{"sha": "lib-old", "commit": {"committer": {"date": "2024-06-01T10:00:00Z"}}}`)
+		})
+		mux.HandleFunc("GET /repos/other/lib/commits", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("sha") != "lib-new" || r.URL.Query().Get("since") != "2024-06-01T10:00:00Z" {
+				t.Errorf("unexpected commits listing: %s", r.URL.RawQuery)
+			}
+			fmt.Fprint(w, `[{"sha": "lib-new", "commit": {"message": "Fix lib"}}, {"sha": "lib-old", "commit": {"message": "Old"}}]`)
+		})
+		rnw := newTestWriter(t, Options{}, mux)
+
+		smChanges, err := rnw.getChangesForSubmodules(t.Context(), "owner", "repo", "new", "old")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(smChanges) != 1 || smChanges[0].Err != nil || !smChanges[0].Uncompared {
+			t.Fatalf("expected an uncompared submodule, got %+v", smChanges)
+		}
+		if want := []Change{{Repo: "other/lib", SHA: "lib-new", Subject: "Fix lib"}}; !reflect.DeepEqual(smChanges[0].Changes, want) {
+			t.Errorf("status %d: unexpected changes %+v", status, smChanges[0].Changes)
+		}
+		if notes := smChanges[0].render(Options{}); !strings.Contains(notes,
+			"> ⚠️ could not compare submodule commits lib-old and lib-new; listing the commits reachable from lib-new\n") {
+			t.Errorf("expected the uncompared note, got %q", notes)
+		}
+	}
+}
+
+func TestGetChangesForSubmodules_ResolvesTags(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/contents/.gitmodules", gitmodulesHandler(`
+[submodule "lib"]
+	path = lib
+	url = https://github.com/org1/lib.git
+`))
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tree": [{"path": "lib", "type": "commit", "sha": "%s0000000000"}]}`, r.PathValue("sha"))
+	})
+	mux.HandleFunc("GET /repos/org1/lib/compare/{basehead}", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"commits": [{"sha": "l1", "commit": {"message": "Fix lib"}}]}`)
+	})
+	mux.HandleFunc("GET /repos/org1/lib/tags", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, `[{"name": "v1.2.0", "commit": {"sha": "old0000000000"}}]`)
+			return
+		}
+		w.Header().Set("Link", `<`+r.URL.Path+`?page=2>; rel="last"`)
+		fmt.Fprint(w, `[{"name": "v2.0.0", "commit": {"sha": "unrelated"}}]`)
+	})
+	rnw := newTestWriter(t, Options{SubmodulePointerSummary: true}, mux)
+
+	smChanges, err := rnw.getChangesForSubmodules(t.Context(), "owner", "repo", "new", "old")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(smChanges) != 1 {
+		t.Fatalf("got %d submodules, want 1", len(smChanges))
+	}
+	// the untagged new commit falls back to its short SHA
+	want := "Submodule lib updated from v1.2.0 to new0000 (1 commits)"
+	if got := smChanges[0].pointerSummary(); got != want {
+		t.Errorf("pointerSummary() = %q, want %q", got, want)
+	}
+}
+
+func TestGetChangesForSubmodules_NoGitmodules(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/contents/.gitmodules", func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+	})
+	rnw := newTestWriter(t, Options{}, mux)
+
+	smChanges, err := rnw.getChangesForSubmodules(t.Context(), "owner", "repo", "new", "old")
+	if err != nil {
+		t.Fatalf("a repository without submodules must not be an error: %v", err)
+	}
+	if len(smChanges) != 0 {
+		t.Errorf("expected no submodule changes, got %+v", smChanges)
+	}
+}
+
+func TestGetSubmodulePathRepo_NotAFile(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/contents/.gitmodules", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `[{"type": "file", "name": "lib", "path": ".gitmodules/lib"}]`)
+	})
+	rnw := newTestWriter(t, Options{}, mux)
+
+	_, err := rnw.getSubmodulePathRepo(t.Context(), "owner", "repo", "new")
+	if err == nil || !strings.Contains(err.Error(), "is not a file") {
+		t.Errorf("expected a not a file error, got %v", err)
+	}
+}
+
+func TestGetChangesForSubmodules_SubmoduleClient(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/contents/.gitmodules", gitmodulesHandler(`
+[submodule "lib"]
+	path = lib
+	url = https://github.com/other-org/lib.git
+`))
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tree": [{"path": "lib", "type": "commit", "sha": "lib-%s"}]}`, r.PathValue("sha"))
+	})
+	mux.HandleFunc("GET /repos/other-org/lib/compare/lib-old...lib-new", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"commits": [{"sha": "l1", "commit": {"message": "Fix lib"}}]}`)
+	})
+	// the main repository and the submodule require different credentials
+	auth := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		want := "Bearer submodule-token"
+		if strings.HasPrefix(r.URL.Path, "/repos/owner/repo/") {
+			want = "Bearer main-token"
+		}
+		if got := r.Header.Get("Authorization"); got != want {
+			t.Errorf("%s authorized with %q, want %q", r.URL.Path, got, want)
+			http.Error(w, `{"message": "Bad credentials"}`, http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+	baseURL := newTestClient(t, auth).BaseURL
+	mainClient := github.NewClient(nil).WithAuthToken("main-token")
+	mainClient.BaseURL = baseURL
+	submoduleClient := github.NewClient(nil).WithAuthToken("submodule-token")
+	submoduleClient.BaseURL = baseURL
+	rnw := &ReleaseNotesWriter{client: newGitHubAPI(mainClient), submoduleClient: newGitHubAPI(submoduleClient)}
+
+	smChanges, err := rnw.getChangesForSubmodules(t.Context(), "owner", "repo", "new", "old")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(smChanges) != 1 || smChanges[0].Err != nil || len(smChanges[0].Changes) != 1 {
+		t.Errorf("unexpected submodule changes: %+v", smChanges)
+	}
+}
+
+func TestGetChangesForSubmodules_ConcurrentKeepsOrder(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/contents/.gitmodules", gitmodulesHandler(`
+[submodule "first"]
+	path = first
+	url = https://github.com/org1/first.git
+[submodule "second"]
+	path = second
+	url = https://github.com/org2/second.git
+`))
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tree": [
+			{"path": "first", "type": "commit", "sha": "first-%[1]s"},
+			{"path": "second", "type": "commit", "sha": "second-%[1]s"}
+		]}`, r.PathValue("sha"))
+	})
+	// the first submodule can't complete until the second one has been fetched
+	secondDone := make(chan struct{})
+	mux.HandleFunc("GET /repos/org1/first/compare/{basehead}", func(w http.ResponseWriter, _ *http.Request) {
+		<-secondDone
+		fmt.Fprint(w, `{"commits": [{"sha": "f1", "commit": {"message": "Fix first"}}]}`)
+	})
+	mux.HandleFunc("GET /repos/org2/second/compare/{basehead}", func(w http.ResponseWriter, _ *http.Request) {
+		defer close(secondDone)
+		fmt.Fprint(w, `{"commits": [{"sha": "s1", "commit": {"message": "Fix second"}}]}`)
+	})
+	rnw := newTestWriter(t, Options{Concurrency: 2}, mux)
+
+	smChanges, err := rnw.getChangesForSubmodules(t.Context(), "owner", "repo", "new", "old")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var repos []string
+	for _, sm := range smChanges {
+		repos = append(repos, sm.Repo)
+	}
+	if want := []string{"org1/first", "org2/second"}; !reflect.DeepEqual(repos, want) {
+		t.Errorf("submodules = %q, want %q", repos, want)
+	}
+}
+
+func TestSortSubmodulesByChanges(t *testing.T) {
+	changes := func(n int) []Change { return make([]Change, n) }
+	smChanges := []*SubmoduleChanges{
+		{Repo: "org/one", Changes: changes(1)},
+		{Repo: "org/three", Changes: changes(3), Submodules: []*SubmoduleChanges{
+			{Repo: "org/nested-none"},
+			{Repo: "org/nested-two", Changes: changes(2)},
+		}},
+		{Repo: "org/failed", Err: errors.New("boom")},
+		{Repo: "org/other-one", Changes: changes(1)},
+	}
+	sortSubmodulesByChanges(smChanges)
+	var repos []string
+	for _, sm := range flattenSubmodules(smChanges) {
+		repos = append(repos, sm.Repo)
+	}
+	want := []string{"org/three", "org/nested-two", "org/nested-none", "org/one", "org/other-one", "org/failed"}
+	if !reflect.DeepEqual(repos, want) {
+		t.Errorf("sorted submodules = %q, want %q", repos, want)
+	}
+}
+
+func TestReplaceSubmoduleLinks(t *testing.T) {
+	entries := []Change{
+		{Subject: "Fix #12, see other/repo#34 and #56"},
+		{Subject: "#7 at the beginning (#8)"},
+		{Subject: "Not a reference: abc#9, #10a, org/lib#11"},
+	}
+	replaceSubmoduleLinks(entries, githubReference("owner/lib"))
+	want := []string{
+		"Fix owner/lib#12, see other/repo#34 and owner/lib#56",
+		"owner/lib#7 at the beginning (owner/lib#8)",
+		"Not a reference: abc#9, #10a, org/lib#11",
+	}
+	for i := range want {
+		if entries[i].Subject != want[i] {
+			t.Errorf("replaceSubmoduleLinks()[%d] = %q, want %q", i, entries[i].Subject, want[i])
+		}
+	}
+}
+
+func TestSubmoduleReference(t *testing.T) {
+	github := gitSubmodule{Host: "github.com", Repo: "owner/lib"}
+	gitlab := gitSubmodule{Host: gitlabHost, Repo: "group/sub/tool"}
+	tests := []struct {
+		name      string
+		config    Options
+		submodule gitSubmodule
+		want      string
+	}{
+		{"github host", Options{SubmoduleLinks: SubmoduleLinksHost}, github, "Fix owner/lib#12"},
+		{"gitlab host", Options{SubmoduleLinks: SubmoduleLinksHost}, gitlab,
+			"Fix [group/sub/tool#12](https://gitlab.com/group/sub/tool/-/issues/12)"},
+		{"gitlab as github", Options{SubmoduleLinks: SubmoduleLinksGitHub}, gitlab, "Fix group/sub/tool#12"},
+		{"generated link", Options{SubmoduleLinks: SubmoduleLinksHost, GeneratedSubmoduleLink: "mirror/tool"}, gitlab,
+			"Fix mirror/tool#12"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rnw := &ReleaseNotesWriter{config: tt.config}
+			entries := []Change{{Subject: "Fix #12"}}
+			replaceSubmoduleLinks(entries, rnw.submoduleReference(tt.submodule))
+			if entries[0].Subject != tt.want {
+				t.Errorf("replaceSubmoduleLinks() = %q, want %q", entries[0].Subject, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplaceSubmoduleLinks_Unicode(t *testing.T) {
+	entries := []Change{
+		{Subject: "修复崩溃 #12。"},
+		{Subject: "修复崩溃（#13）并更新文档"},
+		{Subject: "Corrección del menú «#14» según José"},
+		{Subject: "No es referencia: café#15, #16é, #17中"},
+		{Subject: "Ünïcödé — #18—#19"},
+	}
+	replaceSubmoduleLinks(entries, githubReference("owner/lib"))
+	want := []string{
+		"修复崩溃 owner/lib#12。",
+		"修复崩溃（owner/lib#13）并更新文档",
+		"Corrección del menú «owner/lib#14» según José",
+		"No es referencia: café#15, #16é, #17中",
+		"Ünïcödé — owner/lib#18—owner/lib#19",
+	}
+	for i := range want {
+		if entries[i].Subject != want[i] {
+			t.Errorf("replaceSubmoduleLinks()[%d] = %q, want %q", i, entries[i].Subject, want[i])
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package releasenotes
+
+import (
+	"slices"
+	"strings"
+)
+
+// knownTrailers are the keys of the git trailers that are removed from the rendered commit messages
+var knownTrailers = []string{
+	"signed-off-by", "co-authored-by", "reviewed-by", "acked-by", "tested-by",
+	"reported-by", "suggested-by", "helped-by", "cc", "change-id",
+}
+
+// trailer is a "Key: value" line at the end of a commit message
+type trailer struct {
+	Key   string
+	Value string
+}
+
+// stripTrailers removes the known git trailers from the end of a commit message, returning the
+// trimmed message and the removed trailers in their original order
+func stripTrailers(message string) (string, []trailer) {
+	lines := strings.Split(strings.TrimSpace(message), "\n")
+	var trailers []trailer
+	for len(lines) > 0 {
+		key, value, ok := strings.Cut(lines[len(lines)-1], ":")
+		key = strings.TrimSpace(key)
+		if !ok || !slices.Contains(knownTrailers, strings.ToLower(key)) {
+			break
+		}
+		trailers = append(trailers, trailer{Key: key, Value: strings.TrimSpace(value)})
+		lines = lines[:len(lines)-1]
+	}
+	slices.Reverse(trailers)
+	return strings.TrimSpace(strings.Join(lines, "\n")), trailers
+}
+
+// coAuthors returns the names of the Co-authored-by trailers, without their email
+func coAuthors(trailers []trailer) []string {
+	var names []string
+	for _, t := range trailers {
+		if !strings.EqualFold(t.Key, "co-authored-by") {
+			continue
+		}
+		name, _, _ := strings.Cut(t.Value, "<")
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,53 @@
+package releasenotes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStripTrailers(t *testing.T) {
+	message := `Details about the change
+
+Signed-off-by: Dev One <one@example.com>
+Co-authored-by: Dev Two <two@example.com>
+reviewed-by: Reviewer <reviewer@example.com>
+Co-Authored-By: Dev Three <three@example.com>`
+
+	body, trailers := stripTrailers(message)
+	if body != "Details about the change" {
+		t.Errorf("body = %q", body)
+	}
+	wantTrailers := []trailer{
+		{Key: "Signed-off-by", Value: "Dev One <one@example.com>"},
+		{Key: "Co-authored-by", Value: "Dev Two <two@example.com>"},
+		{Key: "reviewed-by", Value: "Reviewer <reviewer@example.com>"},
+		{Key: "Co-Authored-By", Value: "Dev Three <three@example.com>"},
+	}
+	if !reflect.DeepEqual(trailers, wantTrailers) {
+		t.Errorf("trailers = %+v, want %+v", trailers, wantTrailers)
+	}
+	if names := coAuthors(trailers); !reflect.DeepEqual(names, []string{"Dev Two", "Dev Three"}) {
+		t.Errorf("coAuthors() = %q", names)
+	}
+}
+
+func TestStripTrailers_KeepsUnknownAndInnerLines(t *testing.T) {
+	// only the known trailers at the end of the message are removed
+	message := "Signed-off-by: in the middle\n\nNote: this is not a trailer"
+	body, trailers := stripTrailers(message)
+	if body != message || trailers != nil {
+		t.Errorf("stripTrailers() = %q, %+v", body, trailers)
+	}
+}
+
+func TestCommitChanges_CoAuthors(t *testing.T) {
+	changes := gitlabChanges("group/lib", SubjectFirstLine, []gitlabCommit{{
+		ID:      "abc",
+		Title:   "Pair programming",
+		Message: "Pair programming\n\nWe did it together\n\nCo-authored-by: Dev Two <two@example.com>\nSigned-off-by: Dev One <one@example.com>",
+	}})
+	if len(changes) != 1 || changes[0].Body != "We did it together" ||
+		!reflect.DeepEqual(changes[0].CoAuthors, []string{"Dev Two"}) {
+		t.Errorf("unexpected changes: %+v", changes)
+	}
+}
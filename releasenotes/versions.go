@@ -0,0 +1,92 @@
+package releasenotes
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// VersionChanges are the changes of the main repository that were introduced by a version
+type VersionChanges struct {
+	Tag     string
+	Changes []Change
+}
+
+// versionTags returns the tags of the releases after the previous tag, up to the Tag, in ascending
+// semver order. The Tag is always the last one, even if it isn't released yet. It returns no tags
+// if the previous tag and the Tag aren't semantic versions in ascending order
+func (rnw *ReleaseNotesWriter) versionTags(ctx context.Context, owner, repo string) ([]string, error) {
+	previous, current := rnw.config.tagVersion(rnw.previousTag), rnw.config.tagVersion(rnw.config.Tag)
+	if !semver.IsValid(previous) || !semver.IsValid(current) || semver.Compare(previous, current) >= 0 {
+		return nil, nil
+	}
+	tags, err := rnw.listReleaseTags(ctx, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("listing the releases: %w", err)
+	}
+	var inRange []string
+	for _, tag := range tags {
+		version := rnw.config.tagVersion(tag)
+		if semver.Compare(version, previous) > 0 && semver.Compare(version, current) < 0 {
+			inRange = append(inRange, tag)
+		}
+	}
+	return append(inRange, rnw.config.Tag), nil
+}
+
+// splitByVersion distributes the changes of the main repository between prevCommit and the Tag
+// among the versions released in between, by comparing each version with the previous one. The
+// changes that aren't found in any of these comparisons are attributed to the Tag. Versions
+// without changes are omitted
+func (rnw *ReleaseNotesWriter) splitByVersion(
+	ctx context.Context, owner, repo, prevCommit string, changes []Change,
+) ([]VersionChanges, error) {
+	tags, err := rnw.versionTags(ctx, owner, repo)
+	if err != nil || len(tags) == 0 {
+		return nil, err
+	}
+	versions := make([]VersionChanges, len(tags))
+	index := make(map[string]int, len(changes))
+	from := prevCommit
+	for i, tag := range tags {
+		versions[i].Tag = tag
+		if i == len(tags)-1 {
+			break
+		}
+		to, err := rnw.commitForTag(ctx, owner, repo, tag)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get commit for tag %s: %w", tag, err)
+		}
+		between, err := rnw.getChanges(ctx, owner, repo, to, from)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get changes of %s: %w", tag, err)
+		}
+		for _, c := range between {
+			if _, ok := index[c.SHA]; !ok {
+				index[c.SHA] = i
+			}
+		}
+		from = to
+	}
+	for _, c := range changes {
+		i, ok := index[c.SHA]
+		if !ok {
+			i = len(tags) - 1
+		}
+		versions[i].Changes = append(versions[i].Changes, c)
+	}
+	return slices.DeleteFunc(versions, func(v VersionChanges) bool { return len(v.Changes) == 0 }), nil
+}
+
+// renderVersions renders the changes of each version under its own heading, demoting the
+// headings of the changes groups
+func renderVersions(config Options, versions []VersionChanges) string {
+	sections := make([]string, 0, len(versions))
+	for _, v := range versions {
+		sections = append(sections, "### "+v.Tag+"\n"+demoteHeadings(renderChanges(config, v.Changes)))
+	}
+	return strings.Join(sections, "\n\n")
+}
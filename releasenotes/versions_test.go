@@ -0,0 +1,95 @@
+package releasenotes
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitByVersion(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/releases", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `[
+			{"tag_name": "v2.0.0"}, {"tag_name": "v1.2.0"}, {"tag_name": "v1.2.0-rc.1", "prerelease": true},
+			{"tag_name": "v1.1.0"}, {"tag_name": "v1.0.0"}
+		]`)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/git/ref/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"object": {"sha": "%s-sha", "type": "commit"}}`, r.PathValue("tag"))
+	})
+	mux.HandleFunc("GET /repos/owner/repo/compare/v1.0.0-sha...v1.1.0-sha", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"commits": [{"sha": "a", "commit": {"message": "First"}}]}`)
+	})
+	// v1.2.0 has no changes of its own, so it's omitted
+	mux.HandleFunc("GET /repos/owner/repo/compare/v1.1.0-sha...v1.2.0-sha", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"commits": []}`)
+	})
+	rnw := newTestWriter(t, Options{Tag: "v1.3.0", Since: SinceLastStable}, mux)
+	rnw.previousTag = "v1.0.0"
+
+	changes := []Change{{SHA: "a", Subject: "First"}, {SHA: "b", Subject: "Second"}}
+	versions, err := rnw.splitByVersion(t.Context(), "owner", "repo", "v1.0.0-sha", changes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []VersionChanges{
+		{Tag: "v1.1.0", Changes: []Change{{SHA: "a", Subject: "First"}}},
+		{Tag: "v1.3.0", Changes: []Change{{SHA: "b", Subject: "Second"}}},
+	}
+	if !reflect.DeepEqual(versions, want) {
+		t.Errorf("splitByVersion() = %+v, want %+v", versions, want)
+	}
+
+	// the versions can't be ordered
+	rnw.previousTag = "latest"
+	if versions, err := rnw.splitByVersion(t.Context(), "owner", "repo", "latest-sha", changes); err != nil || versions != nil {
+		t.Errorf("splitByVersion() = %+v, %v, want no versions", versions, err)
+	}
+}
+
+func TestGenerateNotes_PerVersionMaxWords(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/releases", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `[{"tag_name": "v1.0.1"}, {"tag_name": "v1.0.0"}]`)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/git/ref/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"object": {"sha": "%s-sha", "type": "commit"}}`, r.PathValue("tag"))
+	})
+	mux.HandleFunc("GET /repos/owner/repo/compare/v1.0.0-sha...v1.1.0-sha", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"status": "ahead", "commits": [
+			{"sha": "a", "commit": {"message": "Fix the parser crash"}},
+			{"sha": "b", "commit": {"message": "Add the new exporter"}}]}`)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/compare/v1.0.0-sha...v1.0.1-sha", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"commits": [{"sha": "a", "commit": {"message": "Fix the parser crash"}}]}`)
+	})
+
+	result, err := GenerateNotes(t.Context(), newTestClient(t, mux), Options{
+		Repository: "owner/repo", Tag: "v1.1.0", PreviousTag: "v1.0.0", SkipSubmodules: true,
+		PerVersion: true, MaxWords: 2,
+		Format: FormatMarkdown, PRSuffix: PRSuffixKeep, SubjectMode: SubjectFirstLine, Layout: LayoutDefault,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(result.Notes, "v1.0.1") || !strings.Contains(result.Notes, "* Fix the…") ||
+		!strings.Contains(result.Notes, "* Add the…") || strings.Contains(result.Notes, "parser") {
+		t.Errorf("expected the subjects of each version to be trimmed, got %q", result.Notes)
+	}
+}
+
+func TestRenderMarkdown_Versions(t *testing.T) {
+	notes := renderMarkdown(Options{Repository: "owner/repo", GroupByLabel: true}, ReleaseNotes{
+		Changes: []Change{{Subject: "First"}, {Subject: "Second", Labels: []string{"bug"}}},
+		Versions: []VersionChanges{
+			{Tag: "v1.1.0", Changes: []Change{{Subject: "First"}}},
+			{Tag: "v1.2.0", Changes: []Change{{Subject: "Second", Labels: []string{"bug"}}}},
+		},
+	})
+	want := "## Changes from owner/repo:\n### v1.1.0\n#### Uncategorized\n* First\n\n### v1.2.0\n#### bug\n* Second\n"
+	if notes != want {
+		t.Errorf("renderMarkdown() = %q, want %q", notes, want)
+	}
+}